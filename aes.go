@@ -0,0 +1,41 @@
+package hctr2
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+
+	aesasm "github.com/ericlagergren/hctr2/internal/aes"
+)
+
+var haveAsm = aesasm.Supported
+
+// newCipher creates an AES block cipher.
+//
+// If asm is true, it uses the assembly implementation, which
+// implements XCTRer. Otherwise, it defers to crypto/aes.
+func newCipher(key []byte, asm bool) cipher.Block {
+	if asm {
+		return aesasm.NewCipher(key)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		// len(key) is checked by NewAES.
+		panic(err)
+	}
+	return block
+}
+
+func backend(asm bool) Backend {
+	if !asm || !aesasm.Supported {
+		return Backend{
+			Block:   "crypto/aes",
+			XCTR:    "generic",
+			POLYVAL: polyvalImpl(),
+		}
+	}
+	return Backend{
+		Block:   "assembly",
+		XCTR:    "assembly",
+		POLYVAL: polyvalImpl(),
+	}
+}
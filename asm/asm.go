@@ -9,12 +9,12 @@ import (
 	. "github.com/mmcloughlin/avo/reg"
 )
 
-//go:generate go run asm.go -out ../hctr2_amd64.s -stubs ../stub_amd64.go -pkg hctr2
+//go:generate go run asm.go -out ../internal/aes/xctr_amd64.s -stubs ../internal/aes/stub_amd64.go -pkg aes
 
 var mask Mem
 
 func main() {
-	Package("github.com/ericlagergren/hctr2")
+	Package("github.com/ericlagergren/hctr2/internal/aes")
 	ConstraintExpr("gc,!purego")
 
 	declareXctrAsm()
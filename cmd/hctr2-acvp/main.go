@@ -0,0 +1,145 @@
+// Command hctr2-acvp runs and generates HCTR2 test vectors for
+// cross-validating implementations.
+//
+// Usage:
+//
+//	hctr2-acvp run [-backend name] [-o file] <file.json>...
+//	hctr2-acvp generate [flags]
+//
+// Vectors use the JSON schema from github.com/google/hctr2, as
+// in hctr2test/testdata/HCTR2_AES128.json.
+//
+// The run command computes each vector with the selected backend
+// and writes the results as a JSON array. Each result is the
+// input vector with plaintext_hex and ciphertext_hex replaced by
+// the computed values, plus these fields:
+//
+//	backend                  the implementation used
+//	expected_plaintext_hex   the plaintext from the input, if any
+//	expected_ciphertext_hex  the ciphertext from the input, if any
+//	passed                   whether the computed values match
+//	                         the expected values
+//	error                    why the vector could not be run
+//
+// A vector with only a plaintext is encrypted and a vector with
+// only a ciphertext is decrypted; neither has a passed field.
+// run exits with status 1 if any vector fails.
+//
+// The generate command creates random vectors for each
+// combination of key size, tweak length, and message length and
+// writes them as a JSON array.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "run":
+		err = run(args)
+	case "generate":
+		err = generate(args)
+	case "help", "-h", "-help", "--help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "hctr2-acvp: unknown command %q\n", cmd)
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hctr2-acvp: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage:
+	hctr2-acvp run [-backend name] [-o file] <file.json>...
+	hctr2-acvp generate [flags]
+
+Run "hctr2-acvp generate -h" for a list of flags.
+`)
+}
+
+// backendOptions returns the Options that select the backend
+// called name.
+func backendOptions(name string) ([]hctr2.Option, error) {
+	switch name {
+	case "auto":
+		return nil, nil
+	case "generic":
+		return []hctr2.Option{hctr2.Generic()}, nil
+	case "assembly":
+		if hctr2.Implementation().XCTR != "assembly" {
+			return nil, errors.New("the assembly backend is not supported on this CPU")
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+}
+
+const backendUsage = "backend: \"auto\", \"assembly\", or \"generic\""
+
+// writeJSON writes v to path, or to stdout if path is empty or
+// "-".
+func writeJSON(path string, v interface{}) (err error) {
+	var w io.Writer = os.Stdout
+	if path != "" && path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(v)
+}
+
+// intList is a flag.Value for a comma-separated list of
+// integers.
+type intList []int
+
+func (l *intList) String() string {
+	s := make([]string, len(*l))
+	for i, n := range *l {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *intList) Set(s string) error {
+	var list intList
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid length: %d", n)
+		}
+		list = append(list, n)
+	}
+	*l = list
+	return nil
+}
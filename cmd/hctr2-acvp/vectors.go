@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ericlagergren/hctr2"
+	"github.com/ericlagergren/hctr2/internal/vectors"
+)
+
+// result is the outcome of running a vector.
+type result struct {
+	vectors.Vector
+	Backend            string `json:"backend"`
+	ExpectedPlaintext  string `json:"expected_plaintext_hex,omitempty"`
+	ExpectedCiphertext string `json:"expected_ciphertext_hex,omitempty"`
+	Passed             *bool  `json:"passed,omitempty"`
+	Error              string `json:"error,omitempty"`
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	backend := fs.String("backend", "auto", backendUsage)
+	out := fs.String("o", "-", "write results to `file`")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("run: expected at least one file")
+	}
+	opts, err := backendOptions(*backend)
+	if err != nil {
+		return err
+	}
+
+	results := []result{}
+	failed := 0
+	for _, path := range fs.Args() {
+		vecs, err := vectors.Load(path)
+		if err != nil {
+			return err
+		}
+		for _, v := range vecs {
+			r := runVector(v, opts)
+			if r.Error != "" || (r.Passed != nil && !*r.Passed) {
+				failed++
+			}
+			results = append(results, r)
+		}
+	}
+	if err := writeJSON(*out, results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d vectors failed", failed, len(results))
+	}
+	return nil
+}
+
+func runVector(v vectors.Vector, opts []hctr2.Option) result {
+	r := result{
+		Vector:             v,
+		ExpectedPlaintext:  v.Plaintext,
+		ExpectedCiphertext: v.Ciphertext,
+	}
+	res, err := vectors.Run(v, opts...)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Backend = res.Backend.String()
+	switch {
+	case v.Plaintext != "" && v.Ciphertext != "":
+		r.Ciphertext = hex.EncodeToString(res.Ciphertext)
+		passed := r.Ciphertext == v.Ciphertext &&
+			hex.EncodeToString(res.Plaintext) == v.Plaintext
+		r.Passed = &passed
+	case v.Plaintext != "":
+		r.Ciphertext = hex.EncodeToString(res.Ciphertext)
+	default:
+		r.Plaintext = hex.EncodeToString(res.Plaintext)
+	}
+	return r
+}
+
+func generate(args []string) error {
+	keySizes := intList{16, 24, 32}
+	tweakLens := intList{0, 1, 16, 32, 47}
+	msgLens := intList{16, 17, 31, 48, 128, 255, 512}
+
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	fs.Var(&keySizes, "key-sizes", "comma-separated key sizes in bytes")
+	fs.Var(&tweakLens, "tweak-lengths", "comma-separated tweak lengths in bytes")
+	fs.Var(&msgLens, "lengths", "comma-separated message lengths in bytes")
+	count := fs.Int("count", 10, "number of vectors for each combination")
+	backend := fs.String("backend", "auto", backendUsage)
+	out := fs.String("o", "-", "write vectors to `file`")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("generate: unexpected arguments")
+	}
+	if *count < 1 {
+		return fmt.Errorf("generate: invalid count: %d", *count)
+	}
+	opts, err := backendOptions(*backend)
+	if err != nil {
+		return err
+	}
+	for _, n := range msgLens {
+		if n < hctr2.BlockSize {
+			return fmt.Errorf("generate: message length %d is shorter than %d",
+				n, hctr2.BlockSize)
+		}
+	}
+
+	vecs := []vectors.Vector{}
+	for _, keySize := range keySizes {
+		for _, msgLen := range msgLens {
+			for _, tweakLen := range tweakLens {
+				for i := 0; i < *count; i++ {
+					v, err := randVector(keySize, tweakLen, msgLen, opts)
+					if err != nil {
+						return err
+					}
+					v.Description = fmt.Sprintf("Random (%2d)", i+1)
+					vecs = append(vecs, v)
+				}
+			}
+		}
+	}
+	return writeJSON(*out, vecs)
+}
+
+func randVector(keySize, tweakLen, msgLen int, opts []hctr2.Option) (vectors.Vector, error) {
+	key := make([]byte, keySize)
+	tweak := make([]byte, tweakLen)
+	plaintext := make([]byte, msgLen)
+	for _, b := range [][]byte{key, tweak, plaintext} {
+		if _, err := rand.Read(b); err != nil {
+			return vectors.Vector{}, err
+		}
+	}
+	c, err := hctr2.NewAES(key, opts...)
+	if err != nil {
+		return vectors.Vector{}, err
+	}
+	ciphertext := make([]byte, msgLen)
+	c.Encrypt(ciphertext, plaintext, tweak)
+
+	v := vectors.Vector{
+		Cipher:     vectors.NewCipherInfo(keySize),
+		Plaintext:  hex.EncodeToString(plaintext),
+		Ciphertext: hex.EncodeToString(ciphertext),
+	}
+	v.Input.Key = hex.EncodeToString(key)
+	v.Input.Tweak = hex.EncodeToString(tweak)
+	return v, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ericlagergren/hctr2"
+	"github.com/ericlagergren/subtle"
+)
+
+// keyFlags are the flags that select the key.
+type keyFlags struct {
+	keyFile  string
+	keyEnv   string
+	passFile string
+	passEnv  string
+	salt     string
+	kdf      hctr2.KDFParams
+}
+
+func (kf *keyFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&kf.keyFile, "key-file", "",
+		"read the hex-encoded key from `path`")
+	fs.StringVar(&kf.keyEnv, "key-env", "",
+		"read the hex-encoded key from the environment variable `name`")
+	fs.StringVar(&kf.passFile, "passphrase-file", "",
+		"read the passphrase from `path`")
+	fs.StringVar(&kf.passEnv, "passphrase-env", "",
+		"read the passphrase from the environment variable `name`")
+	fs.StringVar(&kf.salt, "salt", "",
+		"hex-encoded passphrase salt")
+	kf.kdf = hctr2.DefaultKDFParams()
+	def, err := kf.kdf.MarshalText()
+	if err != nil {
+		panic(err)
+	}
+	fs.Func("kdf",
+		fmt.Sprintf("passphrase KDF `params`, e.g. \"scrypt$ln=15,r=8,p=1,k=32\" (default %q)", def),
+		func(s string) error {
+			return kf.kdf.UnmarshalText([]byte(s))
+		})
+}
+
+// cipher creates the Cipher selected by the flags.
+func (kf *keyFlags) cipher(generic bool) (*hctr2.Cipher, error) {
+	var opts []hctr2.Option
+	if generic {
+		opts = append(opts, hctr2.Generic())
+	}
+
+	n := 0
+	for _, s := range []string{kf.keyFile, kf.keyEnv, kf.passFile, kf.passEnv} {
+		if s != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return nil, errors.New("exactly one of -key-file, -key-env, -passphrase-file, or -passphrase-env is required")
+	}
+
+	var key []byte
+	var err error
+	switch {
+	case kf.keyFile != "":
+		key, err = readHexFile(kf.keyFile)
+	case kf.keyEnv != "":
+		key, err = readHexEnv(kf.keyEnv)
+	default:
+		key, err = kf.passphraseKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer subtle.Wipe(key)
+	return hctr2.NewAES(key, opts...)
+}
+
+func (kf *keyFlags) passphraseKey() ([]byte, error) {
+	if kf.salt == "" {
+		return nil, errors.New("-salt is required with a passphrase")
+	}
+	salt, err := hex.DecodeString(kf.salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+
+	var pass []byte
+	if kf.passFile != "" {
+		pass, err = os.ReadFile(kf.passFile)
+		if err != nil {
+			return nil, err
+		}
+		pass = bytes.TrimRight(pass, "\r\n")
+	} else {
+		s, ok := os.LookupEnv(kf.passEnv)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %q is not set", kf.passEnv)
+		}
+		pass = []byte(s)
+	}
+	defer subtle.Wipe(pass)
+	if len(pass) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	return hctr2.DeriveKeyFromPassphrase(pass, salt, kf.kdf)
+}
+
+func readHexFile(path string) ([]byte, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer subtle.Wipe(buf)
+	return decodeKey(string(bytes.TrimSpace(buf)))
+}
+
+func readHexEnv(name string) ([]byte, error) {
+	s, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %q is not set", name)
+	}
+	return decodeKey(strings.TrimSpace(s))
+}
+
+func decodeKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("key is not valid hex")
+	}
+	return key, nil
+}
+
+// tweakFunc appends the tweak for a sector to dst.
+type tweakFunc func(dst []byte, sector uint64) []byte
+
+func parseTweak(s string, sectorSize int) (tweakFunc, error) {
+	switch {
+	case s == "":
+		return func(dst []byte, _ uint64) []byte {
+			return dst
+		}, nil
+	case s == "sector-le64":
+		if sectorSize <= 0 {
+			return nil, errors.New("-tweak=sector-le64 requires -sector-size")
+		}
+		return func(dst []byte, sector uint64) []byte {
+			var b [8]byte
+			binary.LittleEndian.PutUint64(b[:], sector)
+			return append(dst, b[:]...)
+		}, nil
+	case strings.HasPrefix(s, "hex:"):
+		tweak, err := hex.DecodeString(s[len("hex:"):])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tweak: %w", err)
+		}
+		return func(dst []byte, _ uint64) []byte {
+			return append(dst, tweak...)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown tweak %q", s)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ericlagergren/hctr2"
+	"github.com/ericlagergren/hctr2/internal/vectors"
+)
+
+func verifyVectors(args []string) error {
+	fs := flag.NewFlagSet("verify-vectors", flag.ExitOnError)
+	generic := fs.Bool("generic", false, "force the generic (non-assembly) backend")
+	fs.Parse(args)
+	files := fs.Args()
+
+	var opts []hctr2.Option
+	if *generic {
+		opts = append(opts, hctr2.Generic())
+	}
+	if len(files) == 0 {
+		return errors.New("verify-vectors: expected at least one file")
+	}
+	failed := false
+	for _, path := range files {
+		n, impl, err := verifyFile(path, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: FAIL: %v\n", path, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: ok (%d vectors, %s)\n", path, n, impl)
+	}
+	if failed {
+		return errors.New("verify-vectors: some vectors failed")
+	}
+	return nil
+}
+
+func verifyFile(path string, opts []hctr2.Option) (int, hctr2.Backend, error) {
+	var impl hctr2.Backend
+	vecs, err := vectors.Load(path)
+	if err != nil {
+		return 0, impl, err
+	}
+	for i, v := range vecs {
+		impl, err = vectors.Verify(v, opts...)
+		if err != nil {
+			return i, impl, fmt.Errorf("#%d (%s): %w", i, v.Description, err)
+		}
+	}
+	return len(vecs), impl, nil
+}
@@ -0,0 +1,423 @@
+// Package container implements a versioned file format for
+// data encrypted with HCTR2.
+//
+// A container starts with a fixed-size header (see HeaderSize)
+// followed by fixed-size sectors. Each sector is encrypted with
+// HCTR2 using the sector index and a random file ID as the
+// tweak, so identical sectors in different positions or in
+// different files have unrelated ciphertexts.
+//
+// The AES key is derived from a caller-provided master secret
+// and a random salt stored in the header (see hctr2.DeriveKey).
+// The header also stores a key check value so that Open can
+// detect the wrong master secret.
+//
+// HCTR2 does not authenticate the data. Modifying a sector's
+// ciphertext randomizes the corresponding plaintext sector but
+// is not detected.
+package container
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+// ErrKeyCheck is returned by Open when the master secret does
+// not match the container.
+var ErrKeyCheck = errors.New("container: incorrect key")
+
+// Config configures a new container.
+type Config struct {
+	// Algorithm is the cipher to use.
+	//
+	// If zero, HCTR2AES256 is used.
+	Algorithm Algorithm
+	// SectorSize is the size in bytes of each sector.
+	//
+	// It must be a power of two between MinSectorSize and
+	// MaxSectorSize. If zero, 4096 is used.
+	SectorSize int
+}
+
+// File is an open container.
+//
+// It implements io.ReadWriteSeeker, io.ReaderAt, and
+// io.WriterAt over the plaintext.
+//
+// A File is not safe for concurrent use.
+type File struct {
+	f   *os.File
+	hdr header
+	c   *hctr2.Cipher
+	// pos is the offset used by Read, Write, and Seek.
+	pos int64
+	// sector is a scratch buffer for one sector.
+	sector []byte
+	// tweak is a scratch buffer for the tweak.
+	tweak []byte
+}
+
+var (
+	_ io.ReadWriteSeeker = (*File)(nil)
+	_ io.ReaderAt        = (*File)(nil)
+	_ io.WriterAt        = (*File)(nil)
+)
+
+// Create creates a new, empty container named name.
+//
+// It is an error if the file already exists.
+func Create(name string, master []byte, cfg Config) (*File, error) {
+	if cfg.Algorithm == 0 {
+		cfg.Algorithm = HCTR2AES256
+	}
+	if cfg.Algorithm.keySize() == 0 {
+		return nil, fmt.Errorf("container: unknown algorithm: %d", cfg.Algorithm)
+	}
+	if cfg.SectorSize == 0 {
+		cfg.SectorSize = 4096
+	}
+	if n := cfg.SectorSize; n < MinSectorSize || n > MaxSectorSize || n&(n-1) != 0 {
+		return nil, fmt.Errorf("container: invalid sector size: %d", n)
+	}
+
+	hdr := header{
+		alg:        cfg.Algorithm,
+		sectorSize: uint32(cfg.SectorSize),
+	}
+	if _, err := rand.Read(hdr.fileID[:]); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(hdr.salt[:]); err != nil {
+		return nil, err
+	}
+	c, err := newCipher(master, &hdr)
+	if err != nil {
+		return nil, err
+	}
+	keyCheck(c, &hdr, hdr.check[:])
+
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	file := newFile(f, hdr, c)
+	if err := file.writeHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// Open opens the container named name for reading and
+// writing.
+func Open(name string, master []byte) (*File, error) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	file, err := open(f, master)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func open(f *os.File, master []byte) (*File, error) {
+	buf := make([]byte, HeaderSize)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		if err == io.EOF {
+			err = errors.New("container: not a container file")
+		}
+		return nil, err
+	}
+	var hdr header
+	if err := hdr.unmarshal(buf); err != nil {
+		return nil, err
+	}
+	c, err := newCipher(master, &hdr)
+	if err != nil {
+		return nil, err
+	}
+	var check [checkSize]byte
+	keyCheck(c, &hdr, check[:])
+	if subtle.ConstantTimeCompare(check[:], hdr.check[:]) != 1 {
+		return nil, ErrKeyCheck
+	}
+	return newFile(f, hdr, c), nil
+}
+
+func newFile(f *os.File, hdr header, c *hctr2.Cipher) *File {
+	return &File{
+		f:      f,
+		hdr:    hdr,
+		c:      c,
+		sector: make([]byte, hdr.sectorSize),
+		tweak:  make([]byte, 0, idSize+8),
+	}
+}
+
+// newCipher derives the container's cipher from the master
+// secret.
+func newCipher(master []byte, hdr *header) (*hctr2.Cipher, error) {
+	key, err := hctr2.DeriveKey(master, hdr.alg.keySize(),
+		[]byte("container"), hdr.salt[:])
+	if err != nil {
+		return nil, err
+	}
+	return hctr2.NewAES(key)
+}
+
+// keyCheck computes the key check value.
+//
+// Its tweak is shorter than the sector tweaks, so it never
+// collides with one.
+func keyCheck(c *hctr2.Cipher, hdr *header, dst []byte) {
+	tweak := make([]byte, 0, idSize+3)
+	tweak = append(tweak, hdr.fileID[:]...)
+	tweak = append(tweak, "kcv"...)
+	c.Encrypt(dst, make([]byte, checkSize), tweak)
+}
+
+// Algorithm returns the container's algorithm.
+func (f *File) Algorithm() Algorithm {
+	return f.hdr.alg
+}
+
+// SectorSize returns the container's sector size.
+func (f *File) SectorSize() int {
+	return int(f.hdr.sectorSize)
+}
+
+// Size returns the length of the plaintext.
+func (f *File) Size() int64 {
+	return int64(f.hdr.size)
+}
+
+func (f *File) writeHeader() error {
+	_, err := f.f.WriteAt(f.hdr.marshal(), 0)
+	return err
+}
+
+// sectorTweak returns the tweak for sector i.
+func (f *File) sectorTweak(i int64) []byte {
+	f.tweak = append(f.tweak[:0], f.hdr.fileID[:]...)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(i))
+	return append(f.tweak, b[:]...)
+}
+
+// sectorOffset returns the file offset of sector i.
+func (f *File) sectorOffset(i int64) int64 {
+	return HeaderSize + i*int64(f.hdr.sectorSize)
+}
+
+// nsectors returns the number of sectors needed to hold the
+// plaintext.
+func (f *File) nsectors() int64 {
+	ss := int64(f.hdr.sectorSize)
+	return (int64(f.hdr.size) + ss - 1) / ss
+}
+
+// readSector reads and decrypts sector i into f.sector.
+func (f *File) readSector(i int64) error {
+	if _, err := f.f.ReadAt(f.sector, f.sectorOffset(i)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	f.c.Decrypt(f.sector, f.sector, f.sectorTweak(i))
+	return nil
+}
+
+// writeSector encrypts f.sector and writes it to sector i.
+//
+// It overwrites f.sector.
+func (f *File) writeSector(i int64) error {
+	f.c.Encrypt(f.sector, f.sector, f.sectorTweak(i))
+	_, err := f.f.WriteAt(f.sector, f.sectorOffset(i))
+	return err
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("container: negative offset")
+	}
+	size := f.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+	ss := int64(f.hdr.sectorSize)
+	n := 0
+	for len(p) > 0 && off < size {
+		i := off / ss
+		if err := f.readSector(i); err != nil {
+			return n, err
+		}
+		lo := off % ss
+		hi := ss
+		if end := size - i*ss; end < hi {
+			hi = end
+		}
+		m := copy(p, f.sector[lo:hi])
+		p = p[m:]
+		off += int64(m)
+		n += m
+	}
+	if len(p) > 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt implements io.WriterAt.
+//
+// Writing past the end of the container extends it. The gap, if
+// any, reads as zeros.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("container: negative offset")
+	}
+	if size := f.Size(); off > size {
+		if err := f.zero(size, off); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.writeAt(p, off)
+	if end := uint64(off) + uint64(n); end > f.hdr.size {
+		f.hdr.size = end
+		if herr := f.writeHeader(); err == nil {
+			err = herr
+		}
+	}
+	return n, err
+}
+
+// zero writes zeros to [from, to).
+func (f *File) zero(from, to int64) error {
+	zeros := make([]byte, f.hdr.sectorSize)
+	for from < to {
+		n := int64(len(zeros))
+		if r := to - from; r < n {
+			n = r
+		}
+		m, err := f.writeAt(zeros[:n], from)
+		from += int64(m)
+		if from > int64(f.hdr.size) {
+			f.hdr.size = uint64(from)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *File) writeAt(p []byte, off int64) (int, error) {
+	ss := int64(f.hdr.sectorSize)
+	nsectors := f.nsectors()
+	n := 0
+	for len(p) > 0 {
+		i := off / ss
+		lo := off % ss
+		if lo == 0 && int64(len(p)) >= ss {
+			copy(f.sector, p)
+		} else if i < nsectors {
+			if err := f.readSector(i); err != nil {
+				return n, err
+			}
+			if end := int64(f.hdr.size) - i*ss; end < ss {
+				// Clear stale data past the end of the
+				// plaintext.
+				zeroize(f.sector[end:])
+			}
+		} else {
+			zeroize(f.sector)
+		}
+		m := copy(f.sector[lo:], p)
+		if err := f.writeSector(i); err != nil {
+			return n, err
+		}
+		p = p[m:]
+		off += int64(m)
+		n += m
+	}
+	return n, nil
+}
+
+func zeroize(p []byte) {
+	for i := range p {
+		p[i] = 0
+	}
+}
+
+// Read implements io.Reader.
+func (f *File) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.pos)
+	f.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Write implements io.Writer.
+func (f *File) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.Size()
+	default:
+		return 0, errors.New("container: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("container: negative position")
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+// Truncate changes the length of the plaintext.
+func (f *File) Truncate(size int64) error {
+	if size < 0 {
+		return errors.New("container: negative size")
+	}
+	if old := f.Size(); size > old {
+		if err := f.zero(old, size); err != nil {
+			return err
+		}
+	}
+	f.hdr.size = uint64(size)
+	if err := f.writeHeader(); err != nil {
+		return err
+	}
+	return f.f.Truncate(f.sectorOffset(f.nsectors()))
+}
+
+// Sync commits the container to stable storage.
+func (f *File) Sync() error {
+	return f.f.Sync()
+}
+
+// Close closes the container.
+func (f *File) Close() error {
+	return f.f.Close()
+}
@@ -0,0 +1,198 @@
+package container
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	mrand "math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func randbuf(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+// TestRandomAccess compares a container against an in-memory
+// buffer after random reads, writes, and truncations.
+func TestRandomAccess(t *testing.T) {
+	for _, alg := range []Algorithm{HCTR2AES128, HCTR2AES192, HCTR2AES256} {
+		t.Run(alg.String(), func(t *testing.T) {
+			testRandomAccess(t, alg)
+		})
+	}
+}
+
+func testRandomAccess(t *testing.T, alg Algorithm) {
+	master := randbuf(32)
+	name := filepath.Join(t.TempDir(), "c")
+	f, err := Create(name, master, Config{
+		Algorithm:  alg,
+		SectorSize: 512,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := mrand.New(mrand.NewSource(1))
+	var want []byte
+	for i := 0; i < 500; i++ {
+		switch rng.Intn(4) {
+		case 0, 1:
+			off := rng.Intn(len(want) + 1000)
+			p := randbuf(rng.Intn(2000))
+			if _, err := f.WriteAt(p, int64(off)); err != nil {
+				t.Fatal(err)
+			}
+			if end := off + len(p); end > len(want) {
+				want = append(want, make([]byte, end-len(want))...)
+			}
+			copy(want[off:], p)
+		case 2:
+			size := rng.Intn(len(want) + 1000)
+			if err := f.Truncate(int64(size)); err != nil {
+				t.Fatal(err)
+			}
+			if size < len(want) {
+				want = want[:size]
+			} else {
+				want = append(want, make([]byte, size-len(want))...)
+			}
+		case 3:
+			off := rng.Intn(len(want) + 1)
+			got := make([]byte, rng.Intn(2000))
+			n, err := f.ReadAt(got, int64(off))
+			if n < len(got) && err != io.EOF {
+				t.Fatalf("#%d: expected EOF, got %v", i, err)
+			}
+			if !bytes.Equal(got[:n], want[off:off+n]) {
+				t.Fatalf("#%d: mismatch at offset %d", i, off)
+			}
+		}
+		if f.Size() != int64(len(want)) {
+			t.Fatalf("#%d: expected size %d, got %d", i, len(want), f.Size())
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = Open(name, master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Algorithm() != alg {
+		t.Fatalf("expected %v, got %v", alg, f.Algorithm())
+	}
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("contents mismatch after reopening")
+	}
+}
+
+func TestSeek(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "c")
+	f, err := Create(name, randbuf(32), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("hello, world")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(-5, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 10)
+	n, err := f.Read(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got[:n]) != "world" {
+		t.Fatalf("expected %q, got %q", "world", got[:n])
+	}
+	if _, err := f.Read(got); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+// TestCiphertext tests that identical sectors have unrelated
+// ciphertexts within and across files.
+func TestCiphertext(t *testing.T) {
+	master := randbuf(32)
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte{'a'}, 2*4096)
+
+	var sectors [][]byte
+	for _, name := range []string{"a", "b"} {
+		path := filepath.Join(dir, name)
+		f, err := Create(path, master, Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(raw) != HeaderSize+len(data) {
+			t.Fatalf("expected %d bytes, got %d", HeaderSize+len(data), len(raw))
+		}
+		sectors = append(sectors,
+			raw[HeaderSize:HeaderSize+4096],
+			raw[HeaderSize+4096:])
+	}
+	for i := range sectors {
+		for j := i + 1; j < len(sectors); j++ {
+			if bytes.Equal(sectors[i], sectors[j]) {
+				t.Fatalf("sectors %d and %d are identical", i, j)
+			}
+		}
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "c")
+	f, err := Create(name, randbuf(32), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := Open(name, randbuf(32)); !errors.Is(err, ErrKeyCheck) {
+		t.Fatalf("expected %v, got %v", ErrKeyCheck, err)
+	}
+	if _, err := Create(name, randbuf(32), Config{}); err == nil {
+		t.Fatal("expected an error creating an existing file")
+	}
+
+	junk := filepath.Join(dir, "junk")
+	if err := os.WriteFile(junk, randbuf(HeaderSize), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(junk, randbuf(32)); err == nil {
+		t.Fatal("expected an error opening a non-container file")
+	}
+
+	for _, n := range []int{256, 1000, 2 * MaxSectorSize} {
+		cfg := Config{SectorSize: n}
+		if _, err := Create(filepath.Join(dir, "x"), randbuf(32), cfg); err == nil {
+			t.Fatalf("expected an error for sector size %d", n)
+		}
+	}
+}
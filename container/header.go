@@ -0,0 +1,131 @@
+package container
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// magic identifies a container file.
+const magic = "HCTR2CF\x00"
+
+// version is the current format version.
+const version = 1
+
+// HeaderSize is the size in bytes of the header at the start of
+// every container file. Sectors begin immediately after it.
+//
+// The header is laid out as follows. Integers are little
+// endian.
+//
+//	magic      [8]byte  "HCTR2CF\x00"
+//	version    uint16   1
+//	algorithm  uint8    see Algorithm
+//	reserved   uint8    0
+//	sectorSize uint32
+//	size       uint64   length of the plaintext
+//	fileID     [16]byte random
+//	salt       [32]byte random
+//	check      [16]byte key check value
+//	reserved   [40]byte 0
+const HeaderSize = 128
+
+const (
+	idSize    = 16
+	saltSize  = 32
+	checkSize = 16
+)
+
+// Algorithm identifies the cipher used by a container.
+type Algorithm uint8
+
+const (
+	// HCTR2AES128 is HCTR2 with AES-128.
+	HCTR2AES128 Algorithm = 1
+	// HCTR2AES192 is HCTR2 with AES-192.
+	HCTR2AES192 Algorithm = 2
+	// HCTR2AES256 is HCTR2 with AES-256.
+	HCTR2AES256 Algorithm = 3
+)
+
+// keySize returns the size in bytes of the algorithm's key, or
+// zero if the algorithm is unknown.
+func (a Algorithm) keySize() int {
+	switch a {
+	case HCTR2AES128:
+		return 16
+	case HCTR2AES192:
+		return 24
+	case HCTR2AES256:
+		return 32
+	default:
+		return 0
+	}
+}
+
+func (a Algorithm) String() string {
+	switch a {
+	case HCTR2AES128:
+		return "HCTR2-AES-128"
+	case HCTR2AES192:
+		return "HCTR2-AES-192"
+	case HCTR2AES256:
+		return "HCTR2-AES-256"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", uint8(a))
+	}
+}
+
+const (
+	// MinSectorSize is the smallest allowed sector size.
+	MinSectorSize = 512
+	// MaxSectorSize is the largest allowed sector size.
+	MaxSectorSize = 1 << 20
+)
+
+type header struct {
+	alg        Algorithm
+	sectorSize uint32
+	size       uint64
+	fileID     [idSize]byte
+	salt       [saltSize]byte
+	check      [checkSize]byte
+}
+
+func (h *header) marshal() []byte {
+	buf := make([]byte, HeaderSize)
+	copy(buf, magic)
+	binary.LittleEndian.PutUint16(buf[8:], version)
+	buf[10] = byte(h.alg)
+	binary.LittleEndian.PutUint32(buf[12:], h.sectorSize)
+	binary.LittleEndian.PutUint64(buf[16:], h.size)
+	copy(buf[24:], h.fileID[:])
+	copy(buf[40:], h.salt[:])
+	copy(buf[72:], h.check[:])
+	return buf
+}
+
+func (h *header) unmarshal(buf []byte) error {
+	if len(buf) != HeaderSize || string(buf[:8]) != magic {
+		return errors.New("container: not a container file")
+	}
+	if v := binary.LittleEndian.Uint16(buf[8:]); v != version {
+		return fmt.Errorf("container: unsupported version: %d", v)
+	}
+	h.alg = Algorithm(buf[10])
+	if h.alg.keySize() == 0 {
+		return fmt.Errorf("container: unknown algorithm: %d", buf[10])
+	}
+	h.sectorSize = binary.LittleEndian.Uint32(buf[12:])
+	if n := h.sectorSize; n < MinSectorSize || n > MaxSectorSize || n&(n-1) != 0 {
+		return fmt.Errorf("container: invalid sector size: %d", h.sectorSize)
+	}
+	h.size = binary.LittleEndian.Uint64(buf[16:])
+	if h.size > 1<<62 {
+		return fmt.Errorf("container: invalid size: %d", h.size)
+	}
+	copy(h.fileID[:], buf[24:])
+	copy(h.salt[:], buf[40:])
+	copy(h.check[:], buf[72:])
+	return nil
+}
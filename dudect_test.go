@@ -0,0 +1,185 @@
+//go:build hctr2_dudect
+
+package hctr2
+
+// This file implements a statistical timing-leakage test in the
+// style of dudect (https://eprint.iacr.org/2016/1123.pdf).
+//
+// Each test times an operation over two classes of inputs,
+// a fixed input and random inputs, chosen at random for each
+// measurement. Welch's t-test is then applied to the two
+// distributions of timings. A large |t| means that the timing
+// depends on the input.
+//
+// The tests are slow and sensitive to system noise, so they
+// only run with the hctr2_dudect build tag:
+//
+//	go test -tags hctr2_dudect -run Dudect -dudect.n 1000000
+//
+// Run them on an otherwise idle machine.
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/rand"
+)
+
+var (
+	dudectN = flag.Int("dudect.n", 200000,
+		"number of measurements for each timing test")
+	dudectThreshold = flag.Float64("dudect.t", 10,
+		"fail if |t| exceeds this value")
+)
+
+// welch accumulates two samples for Welch's t-test using
+// Welford's online algorithm.
+type welch struct {
+	n, mean, m2 [2]float64
+}
+
+func (w *welch) push(x float64, class int) {
+	w.n[class]++
+	d := x - w.mean[class]
+	w.mean[class] += d / w.n[class]
+	w.m2[class] += d * (x - w.mean[class])
+}
+
+// t returns Welch's t statistic.
+func (w *welch) t() float64 {
+	v0 := w.m2[0] / (w.n[0] - 1)
+	v1 := w.m2[1] / (w.n[1] - 1)
+	return (w.mean[0] - w.mean[1]) / math.Sqrt(v0/w.n[0]+v1/w.n[1])
+}
+
+// dudect measures fn over two classes of inputs and returns
+// the largest |t| over the uncropped measurements and several
+// cropped subsets.
+//
+// prepare is called before the measurements to set up the
+// input for measurement i. Class 0 is the fixed input and class
+// 1 is a random input.
+func dudect(t *testing.T, prepare func(i, class int), fn func(i int)) float64 {
+	n := *dudectN
+	classes := make([]int, n)
+	for i := range classes {
+		classes[i] = rand.Intn(2)
+		prepare(i, classes[i])
+	}
+
+	times := make([]float64, n)
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		fn(i)
+		times[i] = float64(time.Since(start))
+	}
+
+	// Large measurements are usually caused by interrupts and
+	// other noise, so also test with measurements above several
+	// percentiles removed.
+	sorted := append([]float64(nil), times...)
+	sort.Float64s(sorted)
+	cutoffs := []float64{math.Inf(1)}
+	for _, p := range []float64{0.5, 0.75, 0.9, 0.95, 0.99} {
+		cutoffs = append(cutoffs, sorted[int(p*float64(n))])
+	}
+
+	var max float64
+	for _, cutoff := range cutoffs {
+		var w welch
+		for i, x := range times {
+			if x < cutoff {
+				w.push(x, classes[i])
+			}
+		}
+		tt := math.Abs(w.t())
+		t.Logf("cutoff=%v: n=%v, mean=%.1fns/%.1fns, |t|=%.2f",
+			time.Duration(cutoff), w.n, w.mean[0], w.mean[1], tt)
+		if tt > max {
+			max = tt
+		}
+	}
+	return max
+}
+
+func checkDudect(t *testing.T, max float64) {
+	t.Helper()
+	if max > *dudectThreshold {
+		t.Fatalf("timing depends on the input: |t| = %.2f > %.2f",
+			max, *dudectThreshold)
+	}
+	t.Logf("max |t| = %.2f", max)
+}
+
+// TestDudectEncrypt tests Cipher.Encrypt with fixed versus
+// random plaintexts and tweaks.
+func TestDudectEncrypt(t *testing.T) {
+	test := func(t *testing.T) {
+		for _, size := range []int{BlockSize, 100, 512} {
+			size := size
+			t.Run(fmt.Sprint(size), func(t *testing.T) {
+				c, err := NewAES(randbuf(32))
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Log(c.Implementation())
+
+				n := *dudectN
+				plaintexts := make([]byte, n*size)
+				tweaks := make([]byte, n*BlockSize)
+				dst := make([]byte, size)
+				prepare := func(i, class int) {
+					if class == 1 {
+						rand.Read(plaintexts[i*size : (i+1)*size])
+						rand.Read(tweaks[i*BlockSize : (i+1)*BlockSize])
+					}
+				}
+				fn := func(i int) {
+					c.Encrypt(dst,
+						plaintexts[i*size:(i+1)*size],
+						tweaks[i*BlockSize:(i+1)*BlockSize])
+				}
+				checkDudect(t, dudect(t, prepare, fn))
+			})
+		}
+	}
+	runTests(t, test)
+}
+
+// TestDudectBlock tests the AES block cipher used by NewAES
+// with fixed versus random inputs.
+//
+// Each measurement encrypts a batch of blocks since a single
+// block is too fast to time accurately.
+func TestDudectBlock(t *testing.T) {
+	const batch = 64
+	test := func(t *testing.T) {
+		for _, keySize := range testKeySizes {
+			keySize := keySize
+			t.Run(fmt.Sprintf("AES-%d", keySize*8), func(t *testing.T) {
+				block := newCipher(randbuf(keySize), haveAsm)
+
+				n := *dudectN
+				inputs := make([]byte, n*BlockSize)
+				dst := make([]byte, BlockSize)
+				prepare := func(i, class int) {
+					if class == 1 {
+						rand.Read(inputs[i*BlockSize : (i+1)*BlockSize])
+					}
+				}
+				fn := func(i int) {
+					src := inputs[i*BlockSize : (i+1)*BlockSize]
+					for j := 0; j < batch; j++ {
+						block.Encrypt(dst, src)
+					}
+				}
+				checkDudect(t, dudect(t, prepare, fn))
+			})
+		}
+	}
+	runTests(t, test)
+}
@@ -0,0 +1,126 @@
+// Package field implements deterministic encryption of database
+// fields with HCTR2.
+//
+// Encrypting the same value in the same column always produces
+// the same ciphertext, so encrypted columns can be indexed and
+// searched for equality. The table and column names are used as
+// the HCTR2 tweak, so equal values in different columns have
+// unrelated ciphertexts.
+//
+// HCTR2 requires at least hctr2.BlockSize bytes of input, so
+// each value is padded with a 0x80 byte followed by zero or more
+// zero bytes (ISO/IEC 7816-4 padding) until it is at least
+// hctr2.BlockSize bytes long. Values shorter than
+// hctr2.BlockSize all encrypt to hctr2.BlockSize bytes, which
+// hides their lengths, and longer values grow by exactly one
+// byte.
+//
+// Deterministic encryption reveals which rows have equal
+// values. It does not authenticate the data.
+package field
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ericlagergren/hctr2"
+	"github.com/ericlagergren/hctr2/internal/pool"
+)
+
+// ErrInvalidPadding is returned by Decrypt when a ciphertext
+// does not decrypt to a correctly padded value, which usually
+// means the key, table, or column is wrong.
+var ErrInvalidPadding = errors.New("field: invalid padding")
+
+// Cipher encrypts database fields.
+//
+// A Cipher is safe for concurrent use.
+type Cipher struct {
+	pool *pool.Pool
+}
+
+// New creates a Cipher from c.
+//
+// Values and tweaks can have any length, so c must not be
+// created with hctr2.MinSize greater than hctr2.BlockSize,
+// hctr2.MaxSize, or hctr2.TweakSize.
+//
+// c must not be used after calling New.
+func New(c *hctr2.Cipher) (*Cipher, error) {
+	const maxInt = int(^uint(0) >> 1)
+	for _, x := range []struct {
+		n, tweakLen int
+	}{
+		// The shortest ciphertext and tweak.
+		{hctr2.BlockSize, len(tweak("", ""))},
+		// Tweaks have different lengths.
+		{hctr2.BlockSize, len(tweak("", "")) + 1},
+		// Values can be any length.
+		{maxInt, len(tweak("", ""))},
+	} {
+		if err := c.Check(x.n, x.tweakLen); err != nil {
+			return nil, fmt.Errorf("field: unsupported cipher: %w", err)
+		}
+	}
+	return &Cipher{pool: pool.New(c)}, nil
+}
+
+// Overhead returns the size of the ciphertext for a value of
+// length n.
+func Overhead(n int) int {
+	if n < hctr2.BlockSize {
+		return hctr2.BlockSize
+	}
+	return n + 1
+}
+
+// tweak encodes the table and column as the tweak.
+func tweak(table, column string) []byte {
+	t := make([]byte, 8+len(table)+len(column))
+	binary.LittleEndian.PutUint32(t[0:], uint32(len(table)))
+	n := 4 + copy(t[4:], table)
+	binary.LittleEndian.PutUint32(t[n:], uint32(len(column)))
+	copy(t[n+4:], column)
+	return t
+}
+
+// Encrypt encrypts value for the column in table and returns
+// the ciphertext.
+func (f *Cipher) Encrypt(table, column string, value []byte) []byte {
+	out := make([]byte, Overhead(len(value)))
+	n := copy(out, value)
+	out[n] = 0x80
+
+	c := f.pool.Get()
+	c.Encrypt(out, out, tweak(table, column))
+	f.pool.Put(c)
+	return out
+}
+
+// Decrypt decrypts a ciphertext created by Encrypt for the
+// column in table.
+func (f *Cipher) Decrypt(table, column string, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < hctr2.BlockSize {
+		return nil, errors.New("field: ciphertext too short")
+	}
+	out := make([]byte, len(ciphertext))
+
+	c := f.pool.Get()
+	c.Decrypt(out, ciphertext, tweak(table, column))
+	f.pool.Put(c)
+
+	i := len(out) - 1
+	for i > 0 && out[i] == 0 {
+		i--
+	}
+	if out[i] != 0x80 {
+		return nil, ErrInvalidPadding
+	}
+	if len(out) > hctr2.BlockSize && i != len(out)-1 {
+		// Only values shorter than a block are padded with
+		// more than one byte.
+		return nil, ErrInvalidPadding
+	}
+	return out[:i], nil
+}
@@ -0,0 +1,156 @@
+package field
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+func newCipher(t *testing.T) *Cipher {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	c, err := hctr2.NewAES(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestRoundTrip(t *testing.T) {
+	f := newCipher(t)
+	for n := 0; n < 64; n++ {
+		value := bytes.Repeat([]byte{byte(n)}, n)
+		ct := f.Encrypt("users", "email", value)
+		if len(ct) != Overhead(n) {
+			t.Fatalf("%d: expected %d bytes, got %d", n, Overhead(n), len(ct))
+		}
+		if n < hctr2.BlockSize && len(ct) != hctr2.BlockSize {
+			t.Fatalf("%d: short value was not padded to a block", n)
+		}
+		got, err := f.Decrypt("users", "email", ct)
+		if err != nil {
+			t.Fatalf("%d: %v", n, err)
+		}
+		if !bytes.Equal(got, value) {
+			t.Fatalf("%d: expected %x, got %x", n, value, got)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	f := newCipher(t)
+	value := []byte("alice@example.com")
+	a := f.Encrypt("users", "email", value)
+	b := f.Encrypt("users", "email", value)
+	if !bytes.Equal(a, b) {
+		t.Fatal("encryption is not deterministic")
+	}
+	for _, tc := range []struct{ table, column string }{
+		{"users", "name"},
+		{"admins", "email"},
+		{"usersemail", ""},
+		{"", "usersemail"},
+	} {
+		c := f.Encrypt(tc.table, tc.column, value)
+		if bytes.Equal(a, c) {
+			t.Fatalf("%q.%q: same ciphertext as users.email", tc.table, tc.column)
+		}
+		if _, err := f.Decrypt(tc.table, tc.column, a); err == nil {
+			// The padding check is not an authenticator, but
+			// it should reject the wrong column most of the
+			// time.
+			t.Logf("%q.%q: decrypted with the wrong tweak", tc.table, tc.column)
+		}
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	f := newCipher(t)
+	if _, err := f.Decrypt("t", "c", make([]byte, hctr2.BlockSize-1)); err == nil {
+		t.Fatal("expected an error for a short ciphertext")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	f := newCipher(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each goroutine uses a different tweak length so
+			// that the cached tweak state differs.
+			column := fmt.Sprintf("%0*d", i+1, i)
+			value := bytes.Repeat([]byte{byte(i)}, 40+i)
+			for k := 0; k < 100; k++ {
+				ct := f.Encrypt("users", column, value)
+				got, err := f.Decrypt("users", column, ct)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(got, value) {
+					t.Errorf("%d: mismatch", i)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestConcurrentClone tests that creating a new pooled cipher
+// does not race with a pooled cipher that is in use.
+func TestConcurrentClone(t *testing.T) {
+	f := newCipher(t)
+	c := f.pool.Get()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			buf := make([]byte, hctr2.BlockSize+i)
+			c.Encrypt(buf, buf, make([]byte, i))
+		}
+	}()
+	// The pool is empty, so each Get creates a new cipher.
+	for i := 0; i < 100; i++ {
+		f.pool.Get()
+	}
+	<-done
+}
+
+// TestNewCheck tests that New rejects ciphers that cannot
+// encrypt every value.
+func TestNewCheck(t *testing.T) {
+	for i, opt := range []hctr2.Option{
+		hctr2.MinSize(hctr2.BlockSize + 1),
+		hctr2.MaxSize(1 << 20),
+		hctr2.TweakSize(8),
+		hctr2.TweakSize(32),
+	} {
+		c, err := hctr2.NewAES(make([]byte, 32), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := New(c); err == nil {
+			t.Fatalf("#%d: expected an error", i)
+		}
+	}
+	c, err := hctr2.NewAES(make([]byte, 32), hctr2.MinSize(hctr2.BlockSize), hctr2.Zeroize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(c); err != nil {
+		t.Fatal(err)
+	}
+}
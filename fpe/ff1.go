@@ -0,0 +1,223 @@
+package fpe
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ericlagergren/hctr2/internal/aes"
+)
+
+// FF1 is the FF1 format-preserving encryption algorithm.
+//
+// An FF1 is not safe for concurrent use.
+type FF1 struct {
+	block  cipher.Block
+	alpha  *alphabet
+	radix  *big.Int
+	minLen int
+	maxLen uint64
+	// buf is scratch space for the PRF.
+	buf []byte
+}
+
+// NewFF1 creates an FF1 cipher using AES and an alphabet.
+//
+// The key must be 16, 24, or 32 bytes long. The alphabet is the
+// ordered set of symbols in each string, so "0123456789"
+// encrypts decimal digits. It must contain between MinRadix and
+// MaxRadix distinct symbols.
+//
+// Strings must be long enough that there are at least one
+// million of them: six decimal digits or four alphanumeric
+// characters, for example.
+func NewFF1(key []byte, alphabet string) (*FF1, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	alpha, err := newAlphabet(alphabet)
+	if err != nil {
+		return nil, err
+	}
+	return &FF1{
+		block:  aes.NewCipher(key),
+		alpha:  alpha,
+		radix:  big.NewInt(int64(alpha.radix())),
+		minLen: minLen(alpha.radix()),
+		maxLen: 1<<32 - 1,
+	}, nil
+}
+
+// Encrypt encrypts plaintext with tweak.
+func (f *FF1) Encrypt(plaintext string, tweak []byte) (string, error) {
+	return f.crypt(plaintext, tweak, true)
+}
+
+// Decrypt decrypts ciphertext with tweak.
+func (f *FF1) Decrypt(ciphertext string, tweak []byte) (string, error) {
+	return f.crypt(ciphertext, tweak, false)
+}
+
+func (f *FF1) crypt(s string, tweak []byte, seal bool) (string, error) {
+	x, err := f.alpha.numerals(s)
+	if err != nil {
+		return "", err
+	}
+	if len(x) < f.minLen || uint64(len(x)) > f.maxLen {
+		return "", fmt.Errorf("fpe: invalid length: %d", len(x))
+	}
+	if uint64(len(tweak)) > 1<<32-1 {
+		return "", errInvalidTweak
+	}
+	f.ff1(x, tweak, seal)
+	return f.alpha.string(x), nil
+}
+
+// ff1 encrypts or decrypts the numerals in x in place.
+func (f *FF1) ff1(x []uint16, tweak []byte, seal bool) {
+	radix := f.alpha.radix()
+	n := len(x)
+	t := len(tweak)
+
+	// 1. Let u = ⌊n/2⌋; v = n – u.
+	u := n / 2
+	v := n - u
+
+	// 2. Let A = X[1..u]; B = X[u+1..n].
+	A := append([]uint16(nil), x[:u]...)
+	B := append([]uint16(nil), x[u:]...)
+
+	// 3. Let b = ⌈⌈v·LOG(radix)⌉/8⌉.
+	//
+	// This is the number of bytes needed to hold radix^v - 1.
+	max := new(big.Int).Exp(f.radix, big.NewInt(int64(v)), nil)
+	max.Sub(max, big.NewInt(1))
+	b := (max.BitLen() + 7) / 8
+
+	// 4. Let d = 4⌈b/4⌉ + 4.
+	d := 4*((b+3)/4) + 4
+
+	// 5. Let P = [1]^1 || [2]^1 || [1]^1 || [radix]^3 ||
+	//    [10]^1 || [u mod 256]^1 || [n]^4 || [t]^4.
+	var P [16]byte
+	P[0] = 1
+	P[1] = 2
+	P[2] = 1
+	P[3] = byte(radix >> 16)
+	P[4] = byte(radix >> 8)
+	P[5] = byte(radix)
+	P[6] = 10
+	P[7] = byte(u)
+	binary.BigEndian.PutUint32(P[8:], uint32(n))
+	binary.BigEndian.PutUint32(P[12:], uint32(t))
+
+	// Q = T || [0]^((−t−b−1) mod 16) || [i]^1 || [NUM_radix(B)]^b
+	pad := (((-t - b - 1) % 16) + 16) % 16
+	qlen := t + pad + 1 + b
+	if cap(f.buf) < len(P)+qlen {
+		f.buf = make([]byte, len(P)+qlen)
+	}
+	PQ := f.buf[:len(P)+qlen]
+	copy(PQ, P[:])
+	Q := PQ[len(P):]
+	copy(Q, tweak)
+	for i := t; i < t+pad; i++ {
+		Q[i] = 0
+	}
+
+	S := make([]byte, ((d+15)/16)*16)
+	var (
+		y    big.Int
+		c    big.Int
+		mod  big.Int
+		modU = new(big.Int).Exp(f.radix, big.NewInt(int64(u)), nil)
+		modV = new(big.Int).Exp(f.radix, big.NewInt(int64(v)), nil)
+	)
+	round := func(i int, in []uint16) {
+		// i. Let Q = ...
+		Q[t+pad] = byte(i)
+		nb := num(in, f.radix).Bytes()
+		numB := Q[t+pad+1:]
+		for j := range numB {
+			numB[j] = 0
+		}
+		copy(numB[b-len(nb):], nb)
+
+		// ii. Let R = PRF(P || Q).
+		R := S[:16]
+		f.prf(R, PQ)
+
+		// iii. Let S be the first d bytes of
+		//      R || CIPH_K(R ⊕ [1]^16) || CIPH_K(R ⊕ [2]^16) ...
+		for j := 1; j*16 < d; j++ {
+			blk := S[j*16 : (j+1)*16]
+			copy(blk, R)
+			var ctr [8]byte
+			binary.BigEndian.PutUint64(ctr[:], uint64(j))
+			for k := range ctr {
+				blk[8+k] ^= ctr[k]
+			}
+			f.block.Encrypt(blk, blk)
+		}
+
+		// iv. Let y = NUM(S).
+		y.SetBytes(S[:d])
+	}
+
+	if seal {
+		for i := 0; i < 10; i++ {
+			round(i, B)
+
+			// v. If i is even, let m = u; else, let m = v.
+			m, mm := u, modU
+			if i%2 == 1 {
+				m, mm = v, modV
+			}
+			// vi. Let c = (NUM_radix(A) + y) mod radix^m.
+			c.Add(num(A, f.radix), &y)
+			c.Mod(&c, mod.Set(mm))
+			// vii. Let C = STR^m_radix(c).
+			C := make([]uint16, m)
+			str(C, &c, f.radix)
+			// viii. Let A = B.
+			// ix. Let B = C.
+			A, B = B, C
+		}
+	} else {
+		for i := 9; i >= 0; i-- {
+			round(i, A)
+
+			m, mm := u, modU
+			if i%2 == 1 {
+				m, mm = v, modV
+			}
+			// vi. Let c = (NUM_radix(B) – y) mod radix^m.
+			c.Sub(num(B, f.radix), &y)
+			c.Mod(&c, mod.Set(mm))
+			// vii. Let C = STR^m_radix(c).
+			C := make([]uint16, m)
+			str(C, &c, f.radix)
+			// viii. Let B = A.
+			// ix. Let A = C.
+			A, B = C, A
+		}
+	}
+
+	// 7. Return A || B.
+	copy(x, A)
+	copy(x[len(A):], B)
+}
+
+// prf computes PRF(X): the CBC-MAC of X with a zero IV.
+func (f *FF1) prf(dst, X []byte) {
+	var y [16]byte
+	for len(X) > 0 {
+		for i := range y {
+			y[i] ^= X[i]
+		}
+		f.block.Encrypt(y[:], y[:])
+		X = X[16:]
+	}
+	copy(dst, y[:])
+}
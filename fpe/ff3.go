@@ -0,0 +1,202 @@
+package fpe
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"math/big"
+
+	"github.com/ericlagergren/hctr2/internal/aes"
+)
+
+// FF3TweakSize is the size in bytes of an FF3-1 tweak.
+const FF3TweakSize = 7
+
+// FF3 is the FF3-1 format-preserving encryption algorithm.
+//
+// FF3-1 replaces the original FF3 algorithm, which is vulnerable
+// to an attack that exploits its 64-bit tweak.
+//
+// An FF3 is not safe for concurrent use.
+type FF3 struct {
+	block  cipher.Block
+	alpha  *alphabet
+	radix  *big.Int
+	minLen int
+	maxLen int
+}
+
+// NewFF3 creates an FF3-1 cipher using AES and an alphabet.
+//
+// The key must be 16, 24, or 32 bytes long. The alphabet is the
+// ordered set of symbols in each string, so "0123456789"
+// encrypts decimal digits. It must contain between MinRadix and
+// MaxRadix distinct symbols.
+//
+// Strings must be long enough that there are at least one
+// million of them, but short enough that each half fits in 96
+// bits: between 6 and 56 decimal digits, for example.
+func NewFF3(key []byte, alphabet string) (*FF3, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	alpha, err := newAlphabet(alphabet)
+	if err != nil {
+		return nil, err
+	}
+	radix := alpha.radix()
+
+	// maxlen = 2⌊log_radix(2^96)⌋.
+	maxLen := 0
+	limit := new(big.Int).Lsh(big.NewInt(1), 96)
+	r := big.NewInt(int64(radix))
+	for x := new(big.Int).Set(r); x.Cmp(limit) <= 0; x.Mul(x, r) {
+		maxLen++
+	}
+	maxLen *= 2
+
+	f := &FF3{
+		// FF3 uses the byte-reversed key.
+		block:  aes.NewCipher(revb(key)),
+		alpha:  alpha,
+		radix:  r,
+		minLen: minLen(radix),
+		maxLen: maxLen,
+	}
+	if f.minLen > f.maxLen {
+		return nil, fmt.Errorf("fpe: alphabet is too large for FF3-1: %d", radix)
+	}
+	return f, nil
+}
+
+// Encrypt encrypts plaintext with a FF3TweakSize-byte tweak.
+func (f *FF3) Encrypt(plaintext string, tweak []byte) (string, error) {
+	return f.crypt(plaintext, tweak, true)
+}
+
+// Decrypt decrypts ciphertext with a FF3TweakSize-byte tweak.
+func (f *FF3) Decrypt(ciphertext string, tweak []byte) (string, error) {
+	return f.crypt(ciphertext, tweak, false)
+}
+
+func (f *FF3) crypt(s string, tweak []byte, seal bool) (string, error) {
+	if len(tweak) != FF3TweakSize {
+		return "", errInvalidTweak
+	}
+	x, err := f.alpha.numerals(s)
+	if err != nil {
+		return "", err
+	}
+	if len(x) < f.minLen || len(x) > f.maxLen {
+		return "", fmt.Errorf("fpe: invalid length: %d", len(x))
+	}
+
+	// 3. Let T_L = T[0..27] || 0^4 and
+	//    T_R = T[32..55] || T[28..31] || 0^4.
+	var tl, tr [4]byte
+	copy(tl[:], tweak[0:4])
+	tl[3] &= 0xf0
+	copy(tr[:], tweak[4:7])
+	tr[3] = tweak[3] << 4
+
+	f.ff3(x, tl, tr, seal)
+	return f.alpha.string(x), nil
+}
+
+// ff3 encrypts or decrypts the numerals in x in place with the
+// 64-bit tweak T_L || T_R.
+func (f *FF3) ff3(x []uint16, tl, tr [4]byte, seal bool) {
+	n := len(x)
+
+	// 1. Let u = ⌈n/2⌉; v = n – u.
+	u := (n + 1) / 2
+	v := n - u
+
+	// 2. Let A = X[1..u]; B = X[u+1..n].
+	A := append([]uint16(nil), x[:u]...)
+	B := append([]uint16(nil), x[u:]...)
+
+	var (
+		P    [16]byte
+		y    big.Int
+		c    big.Int
+		mod  big.Int
+		tmp  = make([]uint16, n)
+		modU = new(big.Int).Exp(f.radix, big.NewInt(int64(u)), nil)
+		modV = new(big.Int).Exp(f.radix, big.NewInt(int64(v)), nil)
+	)
+	round := func(i int, in []uint16) (int, *big.Int) {
+		// i. If i is even, let m = u and W = T_R, else let
+		//    m = v and W = T_L.
+		m, mm, W := u, modU, tr
+		if i%2 == 1 {
+			m, mm, W = v, modV, tl
+		}
+
+		// ii. Let P = W ⊕ [i]^4 || [NUM_radix(REV(B))]^12.
+		copy(P[:4], W[:])
+		P[3] ^= byte(i)
+		r := tmp[:len(in)]
+		copy(r, in)
+		rev(r)
+		nb := num(r, f.radix).Bytes()
+		for j := 4; j < 16; j++ {
+			P[j] = 0
+		}
+		copy(P[16-len(nb):], nb)
+
+		// iii. Let S = REVB(CIPH_REVB(K)(REVB(P))).
+		S := revb(P[:])
+		f.block.Encrypt(S, S)
+		S = revb(S)
+
+		// iv. Let y = NUM(S).
+		y.SetBytes(S)
+		return m, mm
+	}
+
+	if seal {
+		for i := 0; i < 8; i++ {
+			m, mm := round(i, B)
+
+			// v. Let c = (NUM_radix(REV(A)) + y) mod radix^m.
+			r := tmp[:len(A)]
+			copy(r, A)
+			rev(r)
+			c.Add(num(r, f.radix), &y)
+			c.Mod(&c, mod.Set(mm))
+
+			// vi. Let C = REV(STR^m_radix(c)).
+			C := make([]uint16, m)
+			str(C, &c, f.radix)
+			rev(C)
+
+			// vii. Let A = B.
+			// viii. Let B = C.
+			A, B = B, C
+		}
+	} else {
+		for i := 7; i >= 0; i-- {
+			m, mm := round(i, A)
+
+			// v. Let c = (NUM_radix(REV(B)) – y) mod radix^m.
+			r := tmp[:len(B)]
+			copy(r, B)
+			rev(r)
+			c.Sub(num(r, f.radix), &y)
+			c.Mod(&c, mod.Set(mm))
+
+			// vi. Let C = REV(STR^m_radix(c)).
+			C := make([]uint16, m)
+			str(C, &c, f.radix)
+			rev(C)
+
+			// vii. Let B = A.
+			// viii. Let A = C.
+			A, B = C, A
+		}
+	}
+
+	// 5. Return A || B.
+	copy(x, A)
+	copy(x[len(A):], B)
+}
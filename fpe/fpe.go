@@ -0,0 +1,145 @@
+// Package fpe implements the FF1 and FF3-1 format-preserving
+// encryption algorithms from NIST SP 800-38G Revision 1.
+//
+// Format-preserving encryption encrypts a string of symbols
+// from an alphabet, like the decimal digits of an account
+// number, to another string of the same length over the same
+// alphabet.
+//
+// Both algorithms use the same AES implementation as
+// hctr2.NewAES.
+//
+// [sp800-38g]: https://doi.org/10.6028/NIST.SP.800-38G
+package fpe
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"unicode/utf8"
+)
+
+const (
+	// MinRadix is the smallest allowed alphabet size.
+	MinRadix = 2
+	// MaxRadix is the largest allowed alphabet size.
+	MaxRadix = 1 << 16
+	// minDomain is the smallest allowed domain size.
+	minDomain = 1000000
+)
+
+var errInvalidTweak = errors.New("fpe: invalid tweak size")
+
+// alphabet maps between symbols and numerals.
+type alphabet struct {
+	symbols []rune
+	index   map[rune]uint16
+}
+
+func newAlphabet(s string) (*alphabet, error) {
+	if !utf8.ValidString(s) {
+		return nil, errors.New("fpe: alphabet is not valid UTF-8")
+	}
+	a := &alphabet{
+		symbols: []rune(s),
+		index:   make(map[rune]uint16),
+	}
+	if n := len(a.symbols); n < MinRadix || n > MaxRadix {
+		return nil, fmt.Errorf("fpe: invalid alphabet size: %d", n)
+	}
+	for i, r := range a.symbols {
+		if _, ok := a.index[r]; ok {
+			return nil, fmt.Errorf("fpe: duplicate symbol in alphabet: %q", r)
+		}
+		a.index[r] = uint16(i)
+	}
+	return a, nil
+}
+
+func (a *alphabet) radix() int {
+	return len(a.symbols)
+}
+
+// numerals converts s to numerals.
+func (a *alphabet) numerals(s string) ([]uint16, error) {
+	x := make([]uint16, 0, len(s))
+	for _, r := range s {
+		v, ok := a.index[r]
+		if !ok {
+			return nil, fmt.Errorf("fpe: symbol %q is not in the alphabet", r)
+		}
+		x = append(x, v)
+	}
+	return x, nil
+}
+
+// string converts numerals to a string.
+func (a *alphabet) string(x []uint16) string {
+	r := make([]rune, len(x))
+	for i, v := range x {
+		r[i] = a.symbols[v]
+	}
+	return string(r)
+}
+
+// minLen returns the smallest length such that
+// radix^minLen >= 1,000,000.
+func minLen(radix int) int {
+	n := 0
+	for d := 1; d < minDomain; d *= radix {
+		n++
+	}
+	if n < 2 {
+		n = 2
+	}
+	return n
+}
+
+// num returns NUM_radix(x): x interpreted as a big-endian
+// number in base radix.
+func num(x []uint16, radix *big.Int) *big.Int {
+	z := new(big.Int)
+	var d big.Int
+	for _, v := range x {
+		z.Mul(z, radix)
+		z.Add(z, d.SetUint64(uint64(v)))
+	}
+	return z
+}
+
+// str sets x to STR^m_radix(z): the representation of z as
+// len(x) numerals in base radix.
+func str(x []uint16, z, radix *big.Int) {
+	z = new(big.Int).Set(z)
+	var r big.Int
+	for i := len(x) - 1; i >= 0; i-- {
+		z.QuoRem(z, radix, &r)
+		x[i] = uint16(r.Uint64())
+	}
+}
+
+// rev reverses x in place.
+func rev(x []uint16) {
+	for i, j := 0, len(x)-1; i < j; i, j = i+1, j-1 {
+		x[i], x[j] = x[j], x[i]
+	}
+}
+
+// revb returns a reversed copy of b.
+func revb(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, v := range b {
+		r[len(b)-1-i] = v
+	}
+	return r
+}
+
+// checkKey checks that key is a valid AES key.
+func checkKey(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	default:
+		return fmt.Errorf("fpe: invalid key size: %d", len(key))
+	}
+}
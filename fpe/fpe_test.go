@@ -0,0 +1,205 @@
+package fpe
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	mrand "math/rand"
+	"strings"
+	"testing"
+)
+
+const (
+	digits = "0123456789"
+	base36 = "0123456789abcdefghijklmnopqrstuvwxyz"
+)
+
+func unhex(s string) []byte {
+	p, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func randbuf(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+// TestFF1Vectors tests FF1 with the sample vectors from NIST.
+func TestFF1Vectors(t *testing.T) {
+	const (
+		k128 = "2b7e151628aed2a6abf7158809cf4f3c"
+		k192 = k128 + "ef4359d8d580aa4f"
+		k256 = k192 + "7f036d6f04fc6a94"
+	)
+	for i, v := range []struct {
+		key, alphabet, tweak, plaintext, ciphertext string
+	}{
+		{k128, digits, "", "0123456789", "2433477484"},
+		{k128, digits, "39383736353433323130", "0123456789", "6124200773"},
+		{k128, base36, "3737373770717273373737", "0123456789abcdefghi", "a9tv40mll9kdu509eum"},
+		{k192, digits, "", "0123456789", "2830668132"},
+		{k192, digits, "39383736353433323130", "0123456789", "2496655549"},
+		{k192, base36, "3737373770717273373737", "0123456789abcdefghi", "xbj3kv35jrawxv32ysr"},
+		{k256, digits, "", "0123456789", "6657667009"},
+		{k256, digits, "39383736353433323130", "0123456789", "1001623463"},
+		{k256, base36, "3737373770717273373737", "0123456789abcdefghi", "xs8a0azh2avyalyzuwd"},
+	} {
+		f, err := NewFF1(unhex(v.key), v.alphabet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.Encrypt(v.plaintext, unhex(v.tweak))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v.ciphertext {
+			t.Fatalf("#%d: expected %q, got %q", i, v.ciphertext, got)
+		}
+		got, err = f.Decrypt(v.ciphertext, unhex(v.tweak))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v.plaintext {
+			t.Fatalf("#%d: expected %q, got %q", i, v.plaintext, got)
+		}
+	}
+}
+
+// TestFF3Vectors tests the FF3 core with the sample vectors from
+// NIST, which use the original 64-bit tweak.
+func TestFF3Vectors(t *testing.T) {
+	for i, v := range []struct {
+		key, tweak, plaintext, ciphertext string
+	}{
+		{"ef4359d8d580aa4f7f036d6f04fc6a94", "d8e7920afa330a73", "890121234567890000", "750918814058654607"},
+		{"ef4359d8d580aa4f7f036d6f04fc6a94", "9a768a92f60e12d8", "890121234567890000", "018989839189395384"},
+	} {
+		f, err := NewFF3(unhex(v.key), digits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tweak := unhex(v.tweak)
+		var tl, tr [4]byte
+		copy(tl[:], tweak[:4])
+		copy(tr[:], tweak[4:])
+
+		x, err := f.alpha.numerals(v.plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.ff3(x, tl, tr, true)
+		if got := f.alpha.string(x); got != v.ciphertext {
+			t.Fatalf("#%d: expected %q, got %q", i, v.ciphertext, got)
+		}
+		f.ff3(x, tl, tr, false)
+		if got := f.alpha.string(x); got != v.plaintext {
+			t.Fatalf("#%d: expected %q, got %q", i, v.plaintext, got)
+		}
+	}
+}
+
+type fpeCipher interface {
+	Encrypt(plaintext string, tweak []byte) (string, error)
+	Decrypt(ciphertext string, tweak []byte) (string, error)
+}
+
+// TestRoundTrip tests encrypting then decrypting random strings.
+func TestRoundTrip(t *testing.T) {
+	for _, alphabet := range []string{
+		"01",
+		digits,
+		base36,
+		"αβγδεζηθικλμνξοπρστυφχψω",
+	} {
+		ff1, err := NewFF1(randbuf(32), alphabet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ff3, err := NewFF3(randbuf(16), alphabet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		symbols := []rune(alphabet)
+		for _, tc := range []struct {
+			name  string
+			c     fpeCipher
+			min   int
+			max   int
+			tweak int
+		}{
+			{"FF1", ff1, ff1.minLen, 100, 13},
+			{"FF3-1", ff3, ff3.minLen, ff3.maxLen, FF3TweakSize},
+		} {
+			for n := tc.min; n <= tc.max; n++ {
+				r := make([]rune, n)
+				for i := range r {
+					r[i] = symbols[mrand.Intn(len(symbols))]
+				}
+				plaintext := string(r)
+				tweak := randbuf(tc.tweak)
+				ciphertext, err := tc.c.Encrypt(plaintext, tweak)
+				if err != nil {
+					t.Fatalf("%s: %d: %v", tc.name, n, err)
+				}
+				if got := []rune(ciphertext); len(got) != n {
+					t.Fatalf("%s: %d: expected %d symbols, got %d",
+						tc.name, n, n, len(got))
+				}
+				for _, s := range ciphertext {
+					if !strings.ContainsRune(alphabet, s) {
+						t.Fatalf("%s: %d: symbol %q is not in the alphabet",
+							tc.name, n, s)
+					}
+				}
+				got, err := tc.c.Decrypt(ciphertext, tweak)
+				if err != nil {
+					t.Fatalf("%s: %d: %v", tc.name, n, err)
+				}
+				if got != plaintext {
+					t.Fatalf("%s: %d: expected %q, got %q",
+						tc.name, n, plaintext, got)
+				}
+			}
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, alphabet := range []string{"", "0", "00", "\xff\xfe"} {
+		if _, err := NewFF1(randbuf(16), alphabet); err == nil {
+			t.Fatalf("%q: expected an error", alphabet)
+		}
+	}
+	if _, err := NewFF1(randbuf(20), digits); err == nil {
+		t.Fatal("expected an error for an invalid key size")
+	}
+
+	ff1, err := NewFF1(randbuf(16), digits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ff3, err := NewFF3(randbuf(16), digits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tweak := make([]byte, FF3TweakSize)
+	for _, s := range []string{"12345", "12345a"} {
+		if _, err := ff1.Encrypt(s, nil); err == nil {
+			t.Fatalf("FF1: %q: expected an error", s)
+		}
+		if _, err := ff3.Encrypt(s, tweak); err == nil {
+			t.Fatalf("FF3-1: %q: expected an error", s)
+		}
+	}
+	if _, err := ff3.Encrypt(strings.Repeat("1", 57), tweak); err == nil {
+		t.Fatal("FF3-1: expected an error for a long input")
+	}
+	if _, err := ff3.Encrypt("123456", make([]byte, 8)); err == nil {
+		t.Fatal("FF3-1: expected an error for a 64-bit tweak")
+	}
+}
@@ -0,0 +1,153 @@
+//go:build go1.18
+
+package hctr2
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ericlagergren/hctr2/internal/ref"
+)
+
+// fuzzKey creates a key from a fuzzer-provided seed.
+//
+// The first byte of seed selects the key size.
+func fuzzKey(seed []byte) ([]byte, bool) {
+	if len(seed) == 0 {
+		return nil, false
+	}
+	key := make([]byte, testKeySizes[int(seed[0])%len(testKeySizes)])
+	copy(key, seed[1:])
+	return key, true
+}
+
+// fuzzCiphers returns a Cipher for each backend.
+func fuzzCiphers(t *testing.T, key []byte) []*Cipher {
+	var cs []*Cipher
+	for _, opts := range [][]Option{nil, {Generic()}} {
+		c, err := NewAES(key, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// FuzzRoundTrip tests that Decrypt inverts Encrypt and that
+// encrypting in place and out of place produce the same result.
+func FuzzRoundTrip(f *testing.F) {
+	for _, n := range []int{15, 16, 17, 31, 32, 33, 63, 64, 65} {
+		f.Add([]byte{byte(n)}, make([]byte, n%20), make([]byte, n))
+	}
+	f.Fuzz(func(t *testing.T, seed, tweak, plaintext []byte) {
+		key, ok := fuzzKey(seed)
+		if !ok || len(plaintext) < BlockSize {
+			return
+		}
+		var want []byte
+		for _, c := range fuzzCiphers(t, key) {
+			// Out of place.
+			ciphertext := make([]byte, len(plaintext))
+			c.Encrypt(ciphertext, plaintext, tweak)
+			if want == nil {
+				want = dup(ciphertext)
+			} else if !bytes.Equal(ciphertext, want) {
+				t.Fatalf("%s: expected %x, got %x", c.Implementation(), want, ciphertext)
+			}
+			got := make([]byte, len(plaintext))
+			c.Decrypt(got, ciphertext, tweak)
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("%s: expected %x, got %x", c.Implementation(), plaintext, got)
+			}
+
+			// In place.
+			got = dup(plaintext)
+			c.Encrypt(got, got, tweak)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: in place: expected %x, got %x", c.Implementation(), want, got)
+			}
+			c.Decrypt(got, got, tweak)
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("%s: in place: expected %x, got %x", c.Implementation(), plaintext, got)
+			}
+		}
+	})
+}
+
+// FuzzTweakLength tests that the cached tweak-length state in
+// initTweak is correct when the tweak length changes between
+// calls on the same Cipher.
+//
+// Each byte of lens is the tweak length for one call. The
+// message alternates between len(plaintext) and
+// len(plaintext)-1 bytes so that both cached states are used.
+func FuzzTweakLength(f *testing.F) {
+	f.Add([]byte{0}, make([]byte, 33), []byte{0, 8, 0, 8})
+	f.Add([]byte{1}, make([]byte, 32), []byte{16, 15, 17, 16})
+	f.Add([]byte{2}, make([]byte, 17), []byte{1, 1, 32, 0, 32})
+	f.Fuzz(func(t *testing.T, seed, plaintext, lens []byte) {
+		key, ok := fuzzKey(seed)
+		if !ok || len(plaintext) < BlockSize {
+			return
+		}
+		tweakBuf := make([]byte, 256)
+		for i := range tweakBuf {
+			tweakBuf[i] = byte(i)
+		}
+		for _, c := range fuzzCiphers(t, key) {
+			for i, n := range lens {
+				tweak := tweakBuf[:n]
+				msg := plaintext
+				if i%2 == 1 && len(msg) > BlockSize {
+					msg = msg[:len(msg)-1]
+				}
+				want := ref.Encrypt(key, tweak, msg)
+
+				got := make([]byte, len(msg))
+				c.Encrypt(got, msg, tweak)
+				if !bytes.Equal(got, want) {
+					t.Fatalf("%s: #%d: expected %x, got %x",
+						c.Implementation(), i, want, got)
+				}
+				c.Decrypt(got, got, tweak)
+				if !bytes.Equal(got, msg) {
+					t.Fatalf("%s: #%d: expected %x, got %x",
+						c.Implementation(), i, msg, got)
+				}
+			}
+		}
+	})
+}
+
+// FuzzXCTR tests Cipher.xctr against the reference
+// implementation.
+func FuzzXCTR(f *testing.F) {
+	for _, n := range []int{0, 1, 15, 16, 17, 63, 64, 65, 127, 128, 129} {
+		f.Add([]byte{byte(n)}, make([]byte, BlockSize), make([]byte, n))
+	}
+	f.Fuzz(func(t *testing.T, seed, nonce, src []byte) {
+		key, ok := fuzzKey(seed)
+		if !ok || len(nonce) != BlockSize {
+			return
+		}
+		want := ref.XCTR(key, nonce, len(src))
+		for i := range want {
+			want[i] ^= src[i]
+		}
+		for _, c := range fuzzCiphers(t, key) {
+			got := make([]byte, len(src))
+			c.xctr(got, src, (*[BlockSize]byte)(nonce))
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: expected %x, got %x", c.Implementation(), want, got)
+			}
+
+			// In place.
+			got = dup(src)
+			c.xctr(got, got, (*[BlockSize]byte)(nonce))
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: in place: expected %x, got %x", c.Implementation(), want, got)
+			}
+		}
+	})
+}
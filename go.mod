@@ -6,6 +6,7 @@ require github.com/ericlagergren/polyval v0.0.0-20220411101811-e25bc10ba391
 
 require (
 	github.com/ericlagergren/subtle v0.0.0-20220507045147-890d697da010
+	golang.org/x/crypto v0.0.0-20220427172511-eb4f295cb31f
 	golang.org/x/exp v0.0.0-20220428152302-39d4317da171
 	golang.org/x/sys v0.0.0-20220408201424-a24fb2fb8a0f
 )
@@ -9,6 +9,11 @@
 // implementation when possible; the block cipher is left to the
 // caller. The recommended block cipher is AES.
 //
+// Tweaks can be at most MaxTweakSize bytes long. There is no
+// maximum message size: XCTR's 64-bit block counter does not
+// wrap for messages shorter than 2^68 bytes, which is longer
+// than any slice.
+//
 // [hctr2]: https://eprint.iacr.org/2021/1441
 package hctr2
 
@@ -17,18 +22,12 @@ import (
 	"crypto/cipher"
 	"encoding/binary"
 	"fmt"
-	"runtime"
 
 	"github.com/ericlagergren/polyval"
-	"golang.org/x/sys/cpu"
 
 	"github.com/ericlagergren/subtle"
 )
 
-var haveAsm = runtime.GOOS == "darwin" ||
-	cpu.ARM64.HasAES ||
-	cpu.X86.HasAES
-
 // BlockSize is the size of block allowed by this package.
 const BlockSize = 16
 
@@ -38,10 +37,28 @@ const BlockSize = 16
 // BlockSize. This restriction may be lifted in the future.
 //
 // The recommended block cipher is AES.
-func New(block cipher.Block) (*Cipher, error) {
+//
+// If the package is built with the hctr2_selftest build tag,
+// the first call to New runs SelfTest and New returns an error
+// if the self-test fails.
+func New(block cipher.Block, opts ...Option) (*Cipher, error) {
+	if powerOnSelfTest {
+		if err := selfTestOnce(); err != nil {
+			return nil, err
+		}
+	}
+	return newCipherWithBlock(block, opts...)
+}
+
+// newCipherWithBlock is New without the power-on self-test.
+func newCipherWithBlock(block cipher.Block, opts ...Option) (*Cipher, error) {
 	if n := block.BlockSize(); n != BlockSize {
 		return nil, fmt.Errorf("hctr2: invalid block size: %d", n)
 	}
+	cfg := newConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 
 	// h ← Ek(bin(0))
 	h := make([]byte, BlockSize)
@@ -49,6 +66,18 @@ func New(block cipher.Block) (*Cipher, error) {
 
 	c := &Cipher{
 		block: block,
+		cfg:   cfg,
+		impl: Backend{
+			Block:   fmt.Sprintf("%T", block),
+			XCTR:    "generic",
+			POLYVAL: polyvalImpl(),
+		},
+	}
+	switch block.(type) {
+	case XCTRer:
+		c.impl.XCTR = fmt.Sprintf("%T", block)
+	case BlockMulti:
+		c.impl.XCTR = fmt.Sprintf("%T.EncryptBlocks", block)
 	}
 	if err := c.h.Init(h); err != nil {
 		return nil, err
@@ -57,30 +86,80 @@ func New(block cipher.Block) (*Cipher, error) {
 	binary.LittleEndian.PutUint64(c.l[0:8], 1)
 	block.Encrypt(c.l[:], c.l[:])
 
+	if cfg.short {
+		// h' ← Ek(bin(2))
+		var hs [BlockSize]byte
+		binary.LittleEndian.PutUint64(hs[0:8], 2)
+		block.Encrypt(hs[:], hs[:])
+		if err := c.hs.Init(hs[:]); err != nil {
+			return nil, err
+		}
+	}
+
 	return c, nil
 }
 
 // NewAES creates a HCTR2 cipher using AES.
 //
 // If supported, the returned Cipher will use a hardware XCTR
-// implementation. Otherwise, it defers to crypto/aes.
+// implementation. Otherwise, it defers to crypto/aes. Use
+// Generic to always defer to crypto/aes.
 //
 // The provided AES key should be either 16, 24, or 32 bytes to
 // choose AES-128, AES-192, or AES-256, respectively.
-func NewAES(key []byte) (*Cipher, error) {
+func NewAES(key []byte, opts ...Option) (*Cipher, error) {
 	switch len(key) {
 	case 16, 24, 32:
 		// OK
 	default:
 		return nil, aes.KeySizeError(len(key))
 	}
-	return New(newCipher(key))
+	asm := haveAsm && !newConfig(opts).generic
+	c, err := New(newCipher(key, asm), opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.impl = backend(asm)
+	return c, nil
+}
+
+// Backend describes the implementations used by a Cipher.
+type Backend struct {
+	// Block is the block cipher implementation.
+	Block string
+	// XCTR is the XCTR implementation.
+	XCTR string
+	// POLYVAL is the POLYVAL implementation.
+	POLYVAL string
+}
+
+func (b Backend) String() string {
+	return fmt.Sprintf("block=%s xctr=%s polyval=%s",
+		b.Block, b.XCTR, b.POLYVAL)
+}
+
+// Implementation reports the implementations that NewAES uses
+// by default.
+func Implementation() Backend {
+	return backend(haveAsm)
+}
+
+// Implementation reports the implementations used by c.
+func (c *Cipher) Implementation() Backend {
+	return c.impl
 }
 
 // Cipher is an instance of the HCTR2 cipher.
+//
+// A Cipher is not safe for concurrent use. Use Clone to create
+// a Cipher for each goroutine.
 type Cipher struct {
 	// block is the underlying block cipher.
 	block cipher.Block
+	// impl describes the implementations used by the cipher.
+	impl Backend
+	// cfg is the configuration set by the caller's Options.
+	cfg config
 	// h is the running POLYVAL.
 	h polyval.Polyval
 	// l is E_k(bin(1)).
@@ -116,51 +195,128 @@ type Cipher struct {
 	state0, state1 polyval.Polyval
 	// init is true if initTweak has been called.
 	init bool
+	// hs is the POLYVAL key used to hash the tweak for short
+	// messages.
+	//
+	// It is only initialized if ShortMessages is used.
+	hs polyval.Polyval
+}
+
+// Clone returns a copy of c that can be used concurrently with
+// c, provided that the underlying block cipher is safe for
+// concurrent use. Block ciphers created by crypto/aes and
+// NewAES are safe for concurrent use.
+func (c *Cipher) Clone() *Cipher {
+	d := *c
+	return &d
+}
+
+// Check reports whether a message of length n can be
+// encrypted or decrypted with a tweak of length tweakLen.
+//
+// Callers that handle untrusted lengths should use Check before
+// calling Encrypt or Decrypt, both of which panic if Check would
+// return an error.
+func (c *Cipher) Check(n, tweakLen int) error {
+	if n < c.cfg.minSize {
+		return fmt.Errorf("%w: %d < %d", ErrMessageSize, n, c.cfg.minSize)
+	}
+	if c.cfg.maxSize > 0 && n > c.cfg.maxSize {
+		return fmt.Errorf("%w: %d > %d", ErrMessageSize, n, c.cfg.maxSize)
+	}
+	if tweakLen < 0 || uint64(tweakLen) > MaxTweakSize {
+		return fmt.Errorf("%w: %d", ErrTweakSize, tweakLen)
+	}
+	if c.cfg.tweakSize >= 0 && tweakLen != c.cfg.tweakSize {
+		return fmt.Errorf("%w: %d != %d", ErrTweakSize, tweakLen, c.cfg.tweakSize)
+	}
+	return nil
 }
 
 // Encrypt encrypts plaintext with tweak and writes the result to
 // ciphertext.
 //
-// plaintext must be at least one block long.
+// plaintext must be at least one block long and must satisfy
+// the limits set by MinSize, MaxSize, and TweakSize.
+//
+// If the Cipher was created with ShortMessages, plaintext can
+// also be between 1 and BlockSize-1 bytes long. Short messages
+// are encrypted as follows, where E is the block cipher and
+// bin(x) is the little-endian encoding of x in BlockSize bytes:
+//
+//	h' ← E(bin(2))
+//	T' ← POLYVAL(h', bin(|T|) || pad(T))
+//	A || B ← P, |A| = |B| = 4*|P| bits
+//	for i ← 0 to 9:
+//	    F ← E(T' ⊕ (bin64(B) || i || |P| || 0^5 || 0x80))
+//	    A, B ← B, A ⊕ F[0;4*|P| bits]
+//	C ← A || B
 //
 // The length of ciphertext must be greater than or equal to the
 // length of plaintext.
 //
 // ciphertext and plaintext must overlap entirely or not at all.
 func (c *Cipher) Encrypt(ciphertext, plaintext, tweak []byte) {
-	if len(plaintext) < BlockSize {
+	c.encrypt(ciphertext, plaintext, tweak, 1)
+}
+
+func (c *Cipher) encrypt(ciphertext, plaintext, tweak []byte, workers int) {
+	if len(plaintext) < BlockSize && !c.cfg.short {
 		panic("hctr2: plaintext is smaller than the block size")
 	}
+	if err := c.Check(len(plaintext), len(tweak)); err != nil {
+		panic(err)
+	}
 	if len(ciphertext) < len(plaintext) {
 		panic("hctr2: ciphertext is smaller than plaintext")
 	}
 	if subtle.InexactOverlap(ciphertext[:len(plaintext)], plaintext) {
 		panic("hctr2: invalid buffer overlap")
 	}
-	c.hctr2(ciphertext[:len(plaintext)], plaintext, tweak, true)
+	if len(plaintext) < BlockSize {
+		c.feistel(ciphertext[:len(plaintext)], plaintext, tweak, true)
+		return
+	}
+	c.hctr2(ciphertext[:len(plaintext)], plaintext, tweak, true, workers)
 }
 
 // Decrypt decrypts ciphertext with tweak and writes the result
 // to plaintext.
 //
+// ciphertext must be at least one block long and must satisfy
+// the limits set by MinSize, MaxSize, and TweakSize. If the
+// Cipher was created with ShortMessages, ciphertext can also be
+// between 1 and BlockSize-1 bytes long.
+//
 // The length of plaintext must be greater than or equal to the
 // length of plaintext.
 //
 // plaintext and ciphertext must overlap entirely or not at all.
 func (c *Cipher) Decrypt(plaintext, ciphertext, tweak []byte) {
-	if len(ciphertext) < BlockSize {
+	c.decrypt(plaintext, ciphertext, tweak, 1)
+}
+
+func (c *Cipher) decrypt(plaintext, ciphertext, tweak []byte, workers int) {
+	if len(ciphertext) < BlockSize && !c.cfg.short {
 		panic("hctr2: ciphertext is smaller than the block size")
 	}
+	if err := c.Check(len(ciphertext), len(tweak)); err != nil {
+		panic(err)
+	}
 	if len(plaintext) < len(ciphertext) {
 		panic("hctr2: plaintext is smaller than ciphertext")
 	}
 	if subtle.InexactOverlap(plaintext[:len(ciphertext)], ciphertext) {
 		panic("hctr2: invalid buffer overlap")
 	}
-	c.hctr2(plaintext[:len(ciphertext)], ciphertext, tweak, false)
+	if len(ciphertext) < BlockSize {
+		c.feistel(plaintext[:len(ciphertext)], ciphertext, tweak, false)
+		return
+	}
+	c.hctr2(plaintext[:len(ciphertext)], ciphertext, tweak, false, workers)
 }
 
-func (c *Cipher) hctr2(dst, src, tweak []byte, seal bool) {
+func (c *Cipher) hctr2(dst, src, tweak []byte, seal bool, workers int) {
 	// Assert that we have at least one block.
 	_ = dst[BlockSize-1]
 	_ = src[BlockSize-1]
@@ -177,7 +333,7 @@ func (c *Cipher) hctr2(dst, src, tweak []byte, seal bool) {
 	var sum [BlockSize]byte
 
 	// MM ← M ⊕ H_h(T, N)
-	polyhash(&c.h, &sum, N)
+	c.polyhashParallel(&c.h, &sum, N, workers)
 	xorBlock(&c.mm, (*[BlockSize]byte)(M), &sum)
 
 	// UU ← Ek(MM)
@@ -192,11 +348,25 @@ func (c *Cipher) hctr2(dst, src, tweak []byte, seal bool) {
 
 	// V ← N ⊕ XCTR_k(S)[0;|N|]
 	V := dst[BlockSize:len(src)]
-	c.xctr(V, N, &c.s)
+	c.xctrParallel(V, N, &c.s, workers)
 
 	// U ← UU ⊕ Hh(T, V)
-	polyhash(&state, &sum, V)
+	c.polyhashParallel(&state, &sum, V, workers)
 	xorBlock((*[BlockSize]byte)(dst), &c.uu, &sum)
+
+	if c.cfg.zeroize {
+		c.wipe()
+	}
+}
+
+// wipe clears the intermediate values derived from the most
+// recent message.
+func (c *Cipher) wipe() {
+	c.h.Reset()
+	c.s = [BlockSize]byte{}
+	c.uu = [BlockSize]byte{}
+	c.mm = [BlockSize]byte{}
+	c.ctr = [BlockSize]byte{}
 }
 
 func (c *Cipher) initTweak(tweak []byte, n int) {
@@ -210,9 +380,12 @@ func (c *Cipher) initTweak(tweak []byte, n int) {
 		//    POLYVAL(h, bin(2*|T| + 2) || pad(T) || M)
 		// else:
 		//    POLYVAL(h, bin(2*|T| + 3) || pad(T) || pad(M || 1))
-		l := uint64(len(tweak)*8*2 + 2)
+		l := uint64(len(tweak))*8*2 + 2
 		block := make([]byte, BlockSize)
 
+		// c.h still contains the previous message.
+		c.h.Reset()
+
 		binary.LittleEndian.PutUint64(block, l)
 		c.state0 = c.h
 		c.state0.Update(block)
@@ -262,8 +435,12 @@ func polyhash(p *polyval.Polyval, sum *[BlockSize]byte, src []byte) {
 
 // xctr performs XCTR_k(S) ^ nonce.
 func (c *Cipher) xctr(dst, src []byte, nonce *[BlockSize]byte) {
-	if v, ok := c.block.(xctrAble); ok {
-		v.xctr(dst, src, nonce)
+	switch v := c.block.(type) {
+	case XCTRer:
+		v.XCTR(dst, src, nonce)
+		return
+	case BlockMulti:
+		xctrBlocks(v, dst, src, nonce)
 		return
 	}
 
@@ -292,8 +469,70 @@ func (c *Cipher) xctr(dst, src []byte, nonce *[BlockSize]byte) {
 	}
 }
 
-type xctrAble interface {
-	xctr(dst, src []byte, nonce *[BlockSize]byte)
+// XCTRer is an optional interface implemented by block ciphers
+// that have their own XCTR implementation, like the assembly
+// AES implementation used by NewAES.
+//
+// If the block cipher passed to New implements XCTRer, the
+// Cipher uses it instead of calling Encrypt for each block.
+type XCTRer interface {
+	// XCTR sets dst to src XORed with the XCTR keystream for
+	// nonce. The keystream is
+	//
+	//	E(nonce ⊕ bin(1)) || E(nonce ⊕ bin(2)) || ...
+	//
+	// where bin(i) is the little-endian encoding of i in
+	// BlockSize bytes.
+	//
+	// len(dst) is at least len(src), and len(src) need not be
+	// a multiple of BlockSize. dst and src overlap entirely or
+	// not at all.
+	XCTR(dst, src []byte, nonce *[BlockSize]byte)
+}
+
+// BlockMulti is an optional interface implemented by block
+// ciphers that can encrypt several blocks at once, like
+// bitsliced or hardware-offloaded implementations.
+//
+// If the block cipher passed to New implements BlockMulti but
+// not XCTRer, the Cipher computes the XCTR keystream in batches
+// with EncryptBlocks instead of calling Encrypt for each block.
+type BlockMulti interface {
+	// EncryptBlocks encrypts each block in src and writes the
+	// results to dst.
+	//
+	// len(src) is a multiple of BlockSize and len(dst) is at
+	// least len(src). dst and src overlap entirely or not at
+	// all.
+	EncryptBlocks(dst, src []byte)
+}
+
+// xctrBatch is the number of blocks xctrBlocks passes to
+// EncryptBlocks at once.
+const xctrBatch = 16
+
+// xctrBlocks performs XCTR_k(S) ^ nonce using EncryptBlocks.
+func xctrBlocks(b BlockMulti, dst, src []byte, nonce *[BlockSize]byte) {
+	var ks [xctrBatch * BlockSize]byte
+	i := uint64(1)
+	for len(src) > 0 {
+		n := len(src)
+		if n > len(ks) {
+			n = len(ks)
+		}
+		m := (n + BlockSize - 1) &^ (BlockSize - 1)
+		for j := 0; j < m; j += BlockSize {
+			ctr := (*[BlockSize]byte)(ks[j : j+BlockSize])
+			binary.LittleEndian.PutUint64(ctr[0:8], i)
+			binary.LittleEndian.PutUint64(ctr[8:16], 0)
+			xorBlock(ctr, ctr, nonce)
+			i++
+		}
+		b.EncryptBlocks(ks[:m], ks[:m])
+		xor(dst, ks[:], src, n)
+		dst = dst[n:]
+		src = src[n:]
+	}
 }
 
 // xorBlocks sets z = x^y.
@@ -2,12 +2,16 @@ package hctr2
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
@@ -130,7 +134,7 @@ func testHCTR2Vectors(t *testing.T) {
 		"HCTR2_AES128",
 	} {
 		t.Run(s, func(t *testing.T) {
-			test(t, filepath.Join("testdata", s+".json"))
+			test(t, filepath.Join("hctr2test", "testdata", s+".json"))
 		})
 	}
 }
@@ -176,7 +180,7 @@ func testXCTRVectors(t *testing.T) {
 		"XCTR_AES128",
 	} {
 		t.Run(s, func(t *testing.T) {
-			test(t, filepath.Join("testdata", s+".json"))
+			test(t, filepath.Join("hctr2test", "testdata", s+".json"))
 		})
 	}
 }
@@ -309,6 +313,107 @@ func testOverlap(t *testing.T, keySize int) {
 	}
 }
 
+// TestGeneric tests that the Generic option selects the generic
+// backend and computes the same result.
+func TestGeneric(t *testing.T) {
+	key := randbuf(32)
+	tweak := randbuf(BlockSize)
+	plaintext := randbuf(BlockSize*4 + 3)
+
+	c, err := NewAES(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Implementation(), Implementation(); got != want {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	g, err := NewAES(key, Generic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := g.Implementation()
+	if impl.Block != "crypto/aes" || impl.XCTR != "generic" {
+		t.Fatalf("expected generic backend, got %v", impl)
+	}
+
+	want := make([]byte, len(plaintext))
+	c.Encrypt(want, plaintext, tweak)
+	got := make([]byte, len(plaintext))
+	g.Encrypt(got, plaintext, tweak)
+	if !bytes.Equal(want, got) {
+		t.Fatalf("expected %x, got %x", want, got)
+	}
+}
+
+// TestOptions tests the message and tweak limits set by
+// Options.
+func TestOptions(t *testing.T) {
+	key := randbuf(16)
+	c, err := NewAES(key,
+		MinSize(512),
+		MaxSize(4096),
+		TweakSize(8),
+		Zeroize(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		n, tweakLen int
+		err         error
+	}{
+		{512, 8, nil},
+		{4096, 8, nil},
+		{511, 8, ErrMessageSize},
+		{4097, 8, ErrMessageSize},
+		{512, 7, ErrTweakSize},
+		{512, 0, ErrTweakSize},
+	} {
+		err := c.Check(tc.n, tc.tweakLen)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("Check(%d, %d): expected %v, got %v",
+				tc.n, tc.tweakLen, tc.err, err)
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected a panic")
+			}
+		}()
+		buf := make([]byte, 256)
+		c.Encrypt(buf, buf, make([]byte, 8))
+	}()
+
+	// Zeroize must not change the output.
+	d, err := NewAES(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := randbuf(1024)
+	tweak := randbuf(8)
+	for i := 0; i < 3; i++ {
+		want := make([]byte, len(plaintext))
+		d.Encrypt(want, plaintext, tweak)
+		got := make([]byte, len(plaintext))
+		c.Encrypt(got, plaintext, tweak)
+		if !bytes.Equal(want, got) {
+			t.Fatalf("#%d: expected %x, got %x", i, want, got)
+		}
+	}
+
+	for _, opts := range [][]Option{
+		{MinSize(BlockSize - 1)},
+		{MinSize(64), MaxSize(32)},
+		{TweakSize(-2)},
+	} {
+		if _, err := NewAES(key, opts...); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+}
+
 // runBench runs both generic and assembly benchmarks.
 func runBench(b *testing.B, fn func(b *testing.B)) {
 	if haveAsm {
@@ -433,3 +538,129 @@ func benchmarkXCTR2(b *testing.B, keyLen, bufLen int) {
 	}
 	sink = buf
 }
+
+// TestLimits tests that Check enforces MaxTweakSize.
+func TestLimits(t *testing.T) {
+	c, err := NewAES(randbuf(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type testCase struct {
+		n, tweakLen int
+		err         error
+	}
+	tests := []testCase{
+		{BlockSize, 0, nil},
+		{BlockSize - 1, 0, ErrMessageSize},
+		{-1, 0, ErrMessageSize},
+		{BlockSize, -1, ErrTweakSize},
+		// There is no maximum message size.
+		{int(^uint(0) >> 1), 0, nil},
+	}
+	if strconv.IntSize == 64 {
+		// Use a variable so that this compiles on 32-bit
+		// platforms.
+		var maxTweak uint64 = MaxTweakSize
+		tests = append(tests, []testCase{
+			{BlockSize, int(maxTweak), nil},
+			{BlockSize, int(maxTweak + 1), ErrTweakSize},
+			{BlockSize, int(maxTweak * 8), ErrTweakSize},
+		}...)
+	}
+	for _, tc := range tests {
+		err := c.Check(tc.n, tc.tweakLen)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("Check(%d, %d): expected %v, got %v",
+				tc.n, tc.tweakLen, tc.err, err)
+		}
+	}
+}
+
+// TestTweakLengthCache tests that changing the length of the
+// tweak between calls does not depend on the previous message.
+func TestTweakLengthCache(t *testing.T) {
+	test := func(t *testing.T) {
+		key := randbuf(16)
+		c, err := NewAES(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, n := range []int{0, 8, 8, 0, 17, 3, 32, 0} {
+			tweak := randbuf(n)
+			plaintext := randbuf(BlockSize + i*7)
+
+			d, err := NewAES(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make([]byte, len(plaintext))
+			d.Encrypt(want, plaintext, tweak)
+
+			got := make([]byte, len(plaintext))
+			c.Encrypt(got, plaintext, tweak)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("#%d: expected %x, got %x", i, want, got)
+			}
+			c.Decrypt(got, got, tweak)
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("#%d: expected %x, got %x", i, plaintext, got)
+			}
+		}
+	}
+	runTests(t, test)
+}
+
+// multiBlock implements BlockMulti.
+type multiBlock struct {
+	cipher.Block
+	calls int
+}
+
+func (b *multiBlock) EncryptBlocks(dst, src []byte) {
+	b.calls++
+	for len(src) > 0 {
+		b.Encrypt(dst, src)
+		dst = dst[BlockSize:]
+		src = src[BlockSize:]
+	}
+}
+
+// TestBlockMulti tests that New uses EncryptBlocks and computes
+// the same result as NewAES.
+func TestBlockMulti(t *testing.T) {
+	key := randbuf(16)
+	want, err := NewAES(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &multiBlock{Block: block}
+	c, err := New(mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Implementation().XCTR; s != "*hctr2.multiBlock.EncryptBlocks" {
+		t.Fatalf("unexpected XCTR implementation: %q", s)
+	}
+	for n := BlockSize; n < 40*BlockSize; n++ {
+		plaintext := randbuf(n)
+		tweak := randbuf(n % 20)
+		wantCt := make([]byte, n)
+		want.Encrypt(wantCt, plaintext, tweak)
+		got := make([]byte, n)
+		c.Encrypt(got, plaintext, tweak)
+		if !bytes.Equal(got, wantCt) {
+			t.Fatalf("%d: expected %x, got %x", n, wantCt, got)
+		}
+		c.Decrypt(got, got, tweak)
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("%d: expected %x, got %x", n, plaintext, got)
+		}
+	}
+	if mb.calls == 0 {
+		t.Fatal("EncryptBlocks was not called")
+	}
+}
@@ -0,0 +1,394 @@
+// Package hctr2test tests block cipher implementations used
+// with HCTR2.
+//
+// It is intended for third-party cipher.Block implementations,
+// like hardware-backed AES, that are used with hctr2.New:
+//
+//	func TestHCTR2(t *testing.T) {
+//		hctr2test.TestAES(t, func(key []byte) (cipher.Block, error) {
+//			return hsm.NewAES(key)
+//		})
+//	}
+//
+// The known-answer tests use the test vectors from
+// github.com/google/hctr2, which are embedded in the package.
+package hctr2test
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"embed"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+//go:embed testdata/*.json
+var testdata embed.FS
+
+// vector is a test vector in the format used by
+// github.com/google/hctr2.
+type vector struct {
+	Description string `json:"description"`
+	Input       struct {
+		Key   string `json:"key_hex"`
+		Tweak string `json:"tweak_hex"`
+		Nonce string `json:"nonce_hex"`
+	} `json:"input"`
+	Plaintext  string `json:"plaintext_hex"`
+	Ciphertext string `json:"ciphertext_hex"`
+}
+
+func loadVectors(t *testing.T, name string) []vector {
+	t.Helper()
+
+	buf, err := testdata.ReadFile("testdata/" + name + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vecs []vector
+	if err := json.Unmarshal(buf, &vecs); err != nil {
+		t.Fatal(err)
+	}
+	return vecs
+}
+
+// TestAES tests an AES implementation.
+//
+// newBlock must create an AES cipher.Block from a 16, 24, or 32
+// byte key. If it returns an error for a particular key size,
+// the tests for that key size are skipped.
+//
+// TestAES runs the HCTR2 and XCTR known-answer tests and then
+// runs TestBlock for each key size.
+func TestAES(t *testing.T, newBlock func(key []byte) (cipher.Block, error)) {
+	for _, keySize := range []int{16, 24, 32} {
+		keySize := keySize
+		t.Run(fmt.Sprintf("AES-%d", keySize*8), func(t *testing.T) {
+			if _, err := newBlock(make([]byte, keySize)); err != nil {
+				t.Skipf("unsupported key size: %v", err)
+			}
+			t.Run("HCTR2", func(t *testing.T) {
+				testHCTR2Vectors(t, newBlock, keySize)
+			})
+			t.Run("XCTR", func(t *testing.T) {
+				testXCTRVectors(t, newBlock, keySize)
+			})
+			t.Run("Block", func(t *testing.T) {
+				block, err := newBlock(randbuf(keySize))
+				if err != nil {
+					t.Fatal(err)
+				}
+				TestBlock(t, block)
+			})
+		})
+	}
+}
+
+func testHCTR2Vectors(t *testing.T, newBlock func([]byte) (cipher.Block, error), keySize int) {
+	vecs := loadVectors(t, fmt.Sprintf("HCTR2_AES%d", keySize*8))
+	for i, v := range vecs {
+		block, err := newBlock(unhex(v.Input.Key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := hctr2.New(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plaintext := unhex(v.Plaintext)
+		tweak := unhex(v.Input.Tweak)
+		want := unhex(v.Ciphertext)
+
+		got := make([]byte, len(plaintext))
+		c.Encrypt(got, plaintext, tweak)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("#%d: (%s): expected %x, got %x",
+				i, v.Description, want, got)
+		}
+		c.Decrypt(got, want, tweak)
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("#%d: (%s): expected %x, got %x",
+				i, v.Description, plaintext, got)
+		}
+	}
+}
+
+func testXCTRVectors(t *testing.T, newBlock func([]byte) (cipher.Block, error), keySize int) {
+	vecs := loadVectors(t, fmt.Sprintf("XCTR_AES%d", keySize*8))
+	for i, v := range vecs {
+		block, err := newBlock(unhex(v.Input.Key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := (*[hctr2.BlockSize]byte)(unhex(v.Input.Nonce))
+		src := unhex(v.Plaintext)
+		want := unhex(v.Ciphertext)
+
+		got := make([]byte, len(src))
+		xctr(block, got, src, nonce)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("#%d: (%s): expected %x, got %x",
+				i, v.Description, want, got)
+		}
+		if x, ok := block.(hctr2.XCTRer); ok {
+			for j := range got {
+				got[j] = 0
+			}
+			x.XCTR(got, src, nonce)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("#%d: (%s): XCTR: expected %x, got %x",
+					i, v.Description, want, got)
+			}
+		}
+	}
+}
+
+// TestBlock tests that block behaves correctly when used with
+// HCTR2.
+//
+// It does not check the output of block against known answers,
+// so it can be used with any 16-byte block cipher. It checks
+// that:
+//
+//   - the block cipher is deterministic and invertible, even
+//     when the input and output are the same or unaligned
+//     slices;
+//   - if the block cipher implements hctr2.XCTRer, XCTR agrees
+//     with XCTR computed from Encrypt;
+//   - if the block cipher implements hctr2.BlockMulti,
+//     EncryptBlocks agrees with Encrypt;
+//   - HCTR2 using the block cipher round trips messages of
+//     many lengths, both in place and out of place;
+//   - HCTR2 rejects inexactly overlapping buffers.
+func TestBlock(t *testing.T, block cipher.Block) {
+	if n := block.BlockSize(); n != hctr2.BlockSize {
+		t.Fatalf("invalid block size: %d", n)
+	}
+	t.Run("Block", func(t *testing.T) {
+		testBlock(t, block)
+	})
+	if _, ok := block.(hctr2.XCTRer); ok {
+		t.Run("XCTR", func(t *testing.T) {
+			testXCTR(t, block)
+		})
+	}
+	if _, ok := block.(hctr2.BlockMulti); ok {
+		t.Run("EncryptBlocks", func(t *testing.T) {
+			testEncryptBlocks(t, block)
+		})
+	}
+	t.Run("RoundTrip", func(t *testing.T) {
+		testRoundTrip(t, block)
+	})
+	t.Run("Overlap", func(t *testing.T) {
+		testOverlap(t, block)
+	})
+}
+
+func testBlock(t *testing.T, block cipher.Block) {
+	const N = hctr2.BlockSize
+	for i := 0; i < 1000; i++ {
+		src := randbuf(N)
+		orig := dup(src)
+
+		want := make([]byte, N)
+		block.Encrypt(want, src)
+		if !bytes.Equal(src, orig) {
+			t.Fatal("Encrypt modified its input")
+		}
+		if bytes.Equal(want, src) {
+			t.Fatalf("Encrypt did not change its input: %x", src)
+		}
+
+		// Encrypt must be deterministic.
+		got := make([]byte, N)
+		block.Encrypt(got, src)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Encrypt is not deterministic: %x != %x", got, want)
+		}
+
+		// In place.
+		got = dup(src)
+		block.Encrypt(got, got)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("in-place Encrypt: expected %x, got %x", want, got)
+		}
+		block.Decrypt(got, got)
+		if !bytes.Equal(got, orig) {
+			t.Fatalf("in-place Decrypt: expected %x, got %x", orig, got)
+		}
+
+		// Unaligned.
+		buf := make([]byte, 2*N+1)
+		copy(buf[1:], src)
+		block.Encrypt(buf[N+1:], buf[1:N+1])
+		if !bytes.Equal(buf[N+1:], want) {
+			t.Fatalf("unaligned Encrypt: expected %x, got %x", want, buf[N+1:])
+		}
+		block.Decrypt(buf[1:N+1], buf[N+1:])
+		if !bytes.Equal(buf[1:N+1], orig) {
+			t.Fatalf("unaligned Decrypt: expected %x, got %x", orig, buf[1:N+1])
+		}
+	}
+}
+
+func testXCTR(t *testing.T, block cipher.Block) {
+	x := block.(hctr2.XCTRer)
+	for n := 0; n < 20*hctr2.BlockSize; n++ {
+		var nonce [hctr2.BlockSize]byte
+		copy(nonce[:], randbuf(len(nonce)))
+		src := randbuf(n)
+
+		want := make([]byte, n)
+		xctr(block, want, src, &nonce)
+
+		got := make([]byte, n)
+		x.XCTR(got, src, &nonce)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%d: expected %x, got %x", n, want, got)
+		}
+
+		// In place.
+		got = dup(src)
+		x.XCTR(got, got, &nonce)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%d: in place: expected %x, got %x", n, want, got)
+		}
+	}
+}
+
+func testEncryptBlocks(t *testing.T, block cipher.Block) {
+	const N = hctr2.BlockSize
+	x := block.(hctr2.BlockMulti)
+	for n := 0; n < 40; n++ {
+		src := randbuf(n * N)
+
+		want := make([]byte, len(src))
+		for i := 0; i < len(src); i += N {
+			block.Encrypt(want[i:i+N], src[i:i+N])
+		}
+
+		got := make([]byte, len(src))
+		x.EncryptBlocks(got, src)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%d: expected %x, got %x", n, want, got)
+		}
+
+		// In place.
+		got = dup(src)
+		x.EncryptBlocks(got, got)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%d: in place: expected %x, got %x", n, want, got)
+		}
+	}
+}
+
+func testRoundTrip(t *testing.T, block cipher.Block) {
+	c, err := hctr2.New(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := hctr2.BlockSize; n < 40*hctr2.BlockSize; n++ {
+		plaintext := randbuf(n)
+		tweak := randbuf(n % 40)
+
+		ciphertext := make([]byte, n)
+		c.Encrypt(ciphertext, plaintext, tweak)
+		if bytes.Equal(ciphertext, plaintext) {
+			t.Fatalf("%d: Encrypt did not change its input", n)
+		}
+
+		got := make([]byte, n)
+		c.Decrypt(got, ciphertext, tweak)
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("%d: expected %x, got %x", n, plaintext, got)
+		}
+
+		// In place.
+		got = dup(plaintext)
+		c.Encrypt(got, got, tweak)
+		if !bytes.Equal(got, ciphertext) {
+			t.Fatalf("%d: in place: expected %x, got %x", n, ciphertext, got)
+		}
+		c.Decrypt(got, got, tweak)
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("%d: in place: expected %x, got %x", n, plaintext, got)
+		}
+
+		// Changing the tweak must change the ciphertext.
+		tweak = append(tweak, 0)
+		c.Encrypt(got, plaintext, tweak)
+		if bytes.Equal(got, ciphertext) {
+			t.Fatalf("%d: ciphertext does not depend on the tweak", n)
+		}
+	}
+}
+
+func testOverlap(t *testing.T, block cipher.Block) {
+	c, err := hctr2.New(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := randbuf(4 * hctr2.BlockSize)
+	for _, off := range []int{1, hctr2.BlockSize - 1, hctr2.BlockSize} {
+		off := off
+		t.Run(fmt.Sprint(off), func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected a panic")
+				}
+			}()
+			c.Encrypt(buf[off:], buf[:3*hctr2.BlockSize], nil)
+		})
+	}
+}
+
+// xctr computes XCTR using block.Encrypt.
+func xctr(block cipher.Block, dst, src []byte, nonce *[hctr2.BlockSize]byte) {
+	var ctr [hctr2.BlockSize]byte
+	for i := uint64(1); len(src) > 0; i++ {
+		binary.LittleEndian.PutUint64(ctr[0:8], i)
+		binary.LittleEndian.PutUint64(ctr[8:16], 0)
+		for j := range ctr {
+			ctr[j] ^= nonce[j]
+		}
+		block.Encrypt(ctr[:], ctr[:])
+		n := len(src)
+		if n > hctr2.BlockSize {
+			n = hctr2.BlockSize
+		}
+		for j := 0; j < n; j++ {
+			dst[j] = src[j] ^ ctr[j]
+		}
+		dst = dst[n:]
+		src = src[n:]
+	}
+}
+
+func randbuf(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+func dup(p []byte) []byte {
+	r := make([]byte, len(p))
+	copy(r, p)
+	return r
+}
+
+func unhex(s string) []byte {
+	p, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
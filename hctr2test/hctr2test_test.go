@@ -0,0 +1,48 @@
+package hctr2test
+
+import (
+	stdaes "crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/ericlagergren/hctr2"
+	"github.com/ericlagergren/hctr2/internal/aes"
+)
+
+func TestStdlibAES(t *testing.T) {
+	TestAES(t, stdaes.NewCipher)
+}
+
+func TestAssemblyAES(t *testing.T) {
+	if !aes.Supported {
+		t.Skip("assembly is not supported")
+	}
+	TestAES(t, func(key []byte) (cipher.Block, error) {
+		return aes.NewCipher(key), nil
+	})
+}
+
+// multiBlock implements hctr2.BlockMulti.
+type multiBlock struct {
+	cipher.Block
+}
+
+var _ hctr2.BlockMulti = multiBlock{}
+
+func (b multiBlock) EncryptBlocks(dst, src []byte) {
+	for len(src) > 0 {
+		b.Encrypt(dst, src)
+		dst = dst[hctr2.BlockSize:]
+		src = src[hctr2.BlockSize:]
+	}
+}
+
+func TestBlockMulti(t *testing.T) {
+	TestAES(t, func(key []byte) (cipher.Block, error) {
+		block, err := stdaes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return multiBlock{block}, nil
+	})
+}
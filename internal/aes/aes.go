@@ -0,0 +1,8 @@
+// Package aes implements AES and AES-XCTR in assembly.
+//
+// It is shared by the packages in this module so that each of
+// them can use the same accelerated block cipher.
+package aes
+
+// BlockSize is the AES block size in bytes.
+const BlockSize = 16
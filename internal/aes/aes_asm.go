@@ -4,16 +4,23 @@
 
 //go:build (amd64 || arm64) && gc && !purego
 
-package hctr2
+package aes
 
 import (
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/binary"
+	"runtime"
 
 	"github.com/ericlagergren/subtle"
+	"golang.org/x/sys/cpu"
 )
 
+// Supported is true if the assembly implementation can be used.
+var Supported = runtime.GOOS == "darwin" ||
+	cpu.ARM64.HasAES ||
+	cpu.X86.HasAES
+
 //go:noescape
 func encryptBlockAsm(nr int, xk *uint32, dst, src *byte)
 
@@ -29,16 +36,20 @@ type aesCipher struct {
 	dec [32 + 28]uint32
 }
 
-var (
-	_ cipher.Block = (*aesCipher)(nil)
-	_ xctrAble     = (*aesCipher)(nil)
-)
+var _ cipher.Block = (*aesCipher)(nil)
 
-func newCipher(key []byte) cipher.Block {
-	if !haveAsm {
+// NewCipher creates an AES block cipher.
+//
+// If Supported is true, the block cipher is implemented in
+// assembly and has an XCTR method. Otherwise, it defers to
+// crypto/aes.
+//
+// The key must be 16, 24, or 32 bytes long.
+func NewCipher(key []byte) cipher.Block {
+	if !Supported {
 		block, err := aes.NewCipher(key)
 		if err != nil {
-			// len(key) is checked by NewAES.
+			// len(key) is checked by the caller.
 			panic(err)
 		}
 		return block
@@ -56,31 +67,33 @@ func (*aesCipher) BlockSize() int {
 
 func (c *aesCipher) Encrypt(dst, src []byte) {
 	if len(src) < BlockSize {
-		panic("hctr2: input not full block")
+		panic("aes: input not full block")
 	}
 	if len(dst) < BlockSize {
-		panic("hctr2: output not full block")
+		panic("aes: output not full block")
 	}
 	if subtle.InexactOverlap(dst[:BlockSize], src[:BlockSize]) {
-		panic("hctr2: invalid buffer overlap")
+		panic("aes: invalid buffer overlap")
 	}
 	encryptBlockAsm(c.nr, &c.enc[0], &dst[0], &src[0])
 }
 
 func (c *aesCipher) Decrypt(dst, src []byte) {
 	if len(src) < BlockSize {
-		panic("hctr2: input not full block")
+		panic("aes: input not full block")
 	}
 	if len(dst) < BlockSize {
-		panic("hctr2: output not full block")
+		panic("aes: output not full block")
 	}
 	if subtle.InexactOverlap(dst[:BlockSize], src[:BlockSize]) {
-		panic("hctr2: invalid buffer overlap")
+		panic("aes: invalid buffer overlap")
 	}
 	decryptBlockAsm(c.nr, &c.dec[0], &dst[0], &src[0])
 }
 
-func (c *aesCipher) xctr(dst, src []byte, nonce *[BlockSize]byte) {
+// XCTR computes XCTR_k(nonce) ^ src and writes the result to
+// dst.
+func (c *aesCipher) XCTR(dst, src []byte, nonce *[BlockSize]byte) {
 	n := len(src) / BlockSize
 	if n > 0 {
 		xctrAsm(c.nr, &c.enc[0], &dst[0], &src[0], n, nonce)
@@ -94,6 +107,18 @@ func (c *aesCipher) xctr(dst, src []byte, nonce *[BlockSize]byte) {
 
 		xorBlock(&ctr, &ctr, nonce)
 		encryptBlockAsm(c.nr, &c.enc[0], &ctr[0], &ctr[0])
-		xor(dst, ctr[:], src, len(src))
+		for i := 0; i < len(src) && i < len(dst); i++ {
+			dst[i] = ctr[i] ^ src[i]
+		}
 	}
 }
+
+// xorBlock sets z = x^y.
+func xorBlock(z, x, y *[BlockSize]byte) {
+	x0 := binary.LittleEndian.Uint64(x[0:])
+	x1 := binary.LittleEndian.Uint64(x[8:])
+	y0 := binary.LittleEndian.Uint64(y[0:])
+	y1 := binary.LittleEndian.Uint64(y[8:])
+	binary.LittleEndian.PutUint64(z[0:], x0^y0)
+	binary.LittleEndian.PutUint64(z[8:], x1^y1)
+}
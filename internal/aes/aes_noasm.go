@@ -0,0 +1,27 @@
+//go:build !(amd64 || arm64) || !gc || purego
+
+package aes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+)
+
+// Supported is true if the assembly implementation can be used.
+const Supported = false
+
+// NewCipher creates an AES block cipher.
+//
+// If Supported is true, the block cipher is implemented in
+// assembly and has an XCTR method. Otherwise, it defers to
+// crypto/aes.
+//
+// The key must be 16, 24, or 32 bytes long.
+func NewCipher(key []byte) cipher.Block {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		// len(key) is checked by the caller.
+		panic(err)
+	}
+	return block
+}
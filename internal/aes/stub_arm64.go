@@ -1,6 +1,6 @@
 //go:build gc && !purego
 
-package hctr2
+package aes
 
 //go:noescape
 func xctrAsm(nr int, xk *uint32, out, in *byte, nblocks int, iv *[BlockSize]byte)
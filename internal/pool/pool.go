@@ -0,0 +1,45 @@
+// Package pool implements a pool of HCTR2 ciphers for packages
+// that share one Cipher between goroutines.
+package pool
+
+import (
+	"sync"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+// Pool is a pool of clones of a Cipher.
+//
+// A Pool is safe for concurrent use.
+type Pool struct {
+	p sync.Pool
+}
+
+// New creates a Pool of clones of c.
+//
+// c must not be used after calling New.
+func New(c *hctr2.Cipher) *Pool {
+	p := &Pool{}
+	// Cloning a Cipher that another goroutine is using races
+	// with its cached tweak state, so clone a private copy that
+	// is never handed out instead.
+	proto := c.Clone()
+	p.p.New = func() interface{} {
+		return proto.Clone()
+	}
+	p.p.Put(c)
+	return p
+}
+
+// Get returns a Cipher from the pool.
+//
+// The Cipher must not be used concurrently until it is returned
+// with Put.
+func (p *Pool) Get() *hctr2.Cipher {
+	return p.p.Get().(*hctr2.Cipher)
+}
+
+// Put returns c to the pool.
+func (p *Pool) Put(c *hctr2.Cipher) {
+	p.p.Put(c)
+}
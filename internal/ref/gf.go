@@ -0,0 +1,83 @@
+package ref
+
+// elem is an element of GF(2^128) defined by the polynomial
+//
+//	x^128 + x^127 + x^126 + x^121 + 1
+//
+// Bit i of elem is the coefficient of x^i. Bytes are converted
+// to and from elem in little-endian order, as in POLYVAL.
+type elem [2]uint64
+
+func toElem(b []byte) elem {
+	var e elem
+	for i := 0; i < n; i++ {
+		e[i/8] |= uint64(b[i]) << (8 * (i % 8))
+	}
+	return e
+}
+
+func (e elem) bytes() []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(e[i/8] >> (8 * (i % 8)))
+	}
+	return b
+}
+
+func (e elem) xor(f elem) elem {
+	return elem{e[0] ^ f[0], e[1] ^ f[1]}
+}
+
+func (e elem) bit(i int) bool {
+	return e[i/64]>>(i%64)&1 == 1
+}
+
+// poly is the low 128 bits of the field polynomial.
+var poly = elem{1, 1<<63 | 1<<62 | 1<<57}
+
+// mulX returns e*x.
+func (e elem) mulX() elem {
+	carry := e.bit(127)
+	e = elem{e[0] << 1, e[1]<<1 | e[0]>>63}
+	if carry {
+		e = e.xor(poly)
+	}
+	return e
+}
+
+// divX returns e*x^-1.
+//
+// If the constant term of e is set, x does not divide e, so
+// the polynomial is added first; this does not change e modulo
+// the polynomial.
+func (e elem) divX() elem {
+	if e.bit(0) {
+		e = e.xor(poly)
+		// Adding the polynomial also sets x^128, which becomes
+		// x^127 after the shift.
+		e = elem{e[0]>>1 | e[1]<<63, e[1]>>1 | 1<<63}
+		return e
+	}
+	return elem{e[0]>>1 | e[1]<<63, e[1] >> 1}
+}
+
+// mul returns a*b.
+func mul(a, b elem) elem {
+	var z elem
+	for i := 127; i >= 0; i-- {
+		z = z.mulX()
+		if b.bit(i) {
+			z = z.xor(a)
+		}
+	}
+	return z
+}
+
+// dot returns a*b*x^-128, the POLYVAL multiplication.
+func dot(a, b elem) elem {
+	z := mul(a, b)
+	for i := 0; i < 128; i++ {
+		z = z.divX()
+	}
+	return z
+}
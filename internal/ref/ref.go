@@ -0,0 +1,174 @@
+// Package ref is a slow reference implementation of HCTR2.
+//
+// It is written directly from the pseudocode in
+// https://eprint.iacr.org/2021/1441.pdf and is only used to
+// test the optimized implementation. It uses its own GF(2^128)
+// arithmetic instead of a POLYVAL library and makes no attempt
+// to be fast or constant time.
+package ref
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+)
+
+// n is the block size in bytes.
+const n = 16
+
+// Encrypt encrypts plaintext with key and tweak.
+//
+//	M || N ← P, |M| = n
+//	MM ← M ⊕ H_h(T, N)
+//	UU ← E_K(MM)
+//	S ← MM ⊕ UU ⊕ L
+//	V ← N ⊕ XCTR_K(S)[0;|N|]
+//	U ← UU ⊕ H_h(T, V)
+//	C ← U || V
+func Encrypt(key, tweak, plaintext []byte) []byte {
+	if len(plaintext) < n {
+		panic("ref: plaintext is too short")
+	}
+	E, h, L := setup(key)
+
+	M, N := plaintext[:n], plaintext[n:]
+	MM := xor(M, hash(h, tweak, N))
+	UU := make([]byte, n)
+	E.Encrypt(UU, MM)
+	S := xor(xor(MM, UU), L)
+	V := xor(N, xctr(E, S, len(N)))
+	U := xor(UU, hash(h, tweak, V))
+	return append(U, V...)
+}
+
+// Decrypt decrypts ciphertext with key and tweak.
+//
+//	U || V ← C, |U| = n
+//	UU ← U ⊕ H_h(T, V)
+//	MM ← D_K(UU)
+//	S ← MM ⊕ UU ⊕ L
+//	N ← V ⊕ XCTR_K(S)[0;|V|]
+//	M ← MM ⊕ H_h(T, N)
+//	P ← M || N
+func Decrypt(key, tweak, ciphertext []byte) []byte {
+	if len(ciphertext) < n {
+		panic("ref: ciphertext is too short")
+	}
+	E, h, L := setup(key)
+
+	U, V := ciphertext[:n], ciphertext[n:]
+	UU := xor(U, hash(h, tweak, V))
+	MM := make([]byte, n)
+	E.Decrypt(MM, UU)
+	S := xor(xor(MM, UU), L)
+	N := xor(V, xctr(E, S, len(V)))
+	M := xor(MM, hash(h, tweak, N))
+	return append(M, N...)
+}
+
+// XCTR computes the first length bytes of XCTR_K(S).
+func XCTR(key, S []byte, length int) []byte {
+	E, _, _ := setup(key)
+	return xctr(E, S, length)
+}
+
+// setup returns the block cipher E_K and the derived values
+//
+//	h ← E_K(bin(0))
+//	L ← E_K(bin(1))
+func setup(key []byte) (E cipher.Block, h, L []byte) {
+	E, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	h = make([]byte, n)
+	E.Encrypt(h, bin(0))
+	L = make([]byte, n)
+	E.Encrypt(L, bin(1))
+	return E, h, L
+}
+
+// hash computes
+//
+//	if n divides |M|:
+//	    POLYVAL(h, bin(2|T| + 2) || pad(T) || M)
+//	else:
+//	    POLYVAL(h, bin(2|T| + 3) || pad(T) || pad(M || 1))
+//
+// where |T| is measured in bits.
+func hash(h, T, M []byte) []byte {
+	var X []byte
+	if len(M)%n == 0 {
+		X = append(X, bin(2*8*uint64(len(T))+2)...)
+		X = append(X, pad(T)...)
+		X = append(X, M...)
+	} else {
+		X = append(X, bin(2*8*uint64(len(T))+3)...)
+		X = append(X, pad(T)...)
+		X = append(X, pad(append(dup(M), 1))...)
+	}
+	return polyval(h, X)
+}
+
+// xctr computes the first length bytes of
+//
+//	E_K(S ⊕ bin(1)) || E_K(S ⊕ bin(2)) || ...
+func xctr(E cipher.Block, S []byte, length int) []byte {
+	var out []byte
+	block := make([]byte, n)
+	for i := uint64(1); len(out) < length; i++ {
+		E.Encrypt(block, xor(S, bin(i)))
+		out = append(out, block...)
+	}
+	return out[:length]
+}
+
+// polyval computes
+//
+//	S_0 ← 0
+//	S_j ← (S_{j-1} ⊕ X_j) • H
+//
+// and returns S_s, where X_1, ..., X_s are the blocks of X.
+func polyval(H, X []byte) []byte {
+	if len(X)%n != 0 {
+		panic("ref: POLYVAL input is not a multiple of the block size")
+	}
+	h := toElem(H)
+	var S elem
+	for len(X) > 0 {
+		S = dot(S.xor(toElem(X[:n])), h)
+		X = X[n:]
+	}
+	return S.bytes()
+}
+
+// bin returns the n-byte little-endian encoding of x.
+func bin(x uint64) []byte {
+	b := make([]byte, n)
+	for i := 0; i < 8; i++ {
+		b[i] = byte(x >> (8 * i))
+	}
+	return b
+}
+
+// pad pads X with zeros to a multiple of n bytes.
+func pad(X []byte) []byte {
+	X = dup(X)
+	for len(X)%n != 0 {
+		X = append(X, 0)
+	}
+	return X
+}
+
+// xor returns x ⊕ y. x and y must be the same length, except
+// that y may be longer.
+func xor(x, y []byte) []byte {
+	z := make([]byte, len(x))
+	for i := range z {
+		z[i] = x[i] ^ y[i]
+	}
+	return z
+}
+
+func dup(p []byte) []byte {
+	return append([]byte(nil), p...)
+}
@@ -0,0 +1,87 @@
+package ref
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	p, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// TestPOLYVAL tests polyval with the example from RFC 8452,
+// appendix A.
+func TestPOLYVAL(t *testing.T) {
+	H := unhex("25629347589242761d31f826ba4b757b")
+	X := unhex("4f4f95668c83dfb6401762bb2d01a262" +
+		"d1a24ddd2721d006bbe45f20d3c9f362")
+	want := unhex("f7a3b47b846119fae5b7866cf5e5b77e")
+	if got := polyval(H, X); !bytes.Equal(got, want) {
+		t.Fatalf("expected %x, got %x", want, got)
+	}
+}
+
+type vector struct {
+	Description string `json:"description"`
+	Input       struct {
+		Key   string `json:"key_hex"`
+		Tweak string `json:"tweak_hex"`
+		Nonce string `json:"nonce_hex"`
+	} `json:"input"`
+	Plaintext  string `json:"plaintext_hex"`
+	Ciphertext string `json:"ciphertext_hex"`
+}
+
+func loadVectors(t *testing.T, name string) []vector {
+	buf, err := os.ReadFile(filepath.Join("..", "..", "hctr2test", "testdata", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vecs []vector
+	if err := json.Unmarshal(buf, &vecs); err != nil {
+		t.Fatal(err)
+	}
+	return vecs
+}
+
+// TestVectors tests the reference implementation with test
+// vectors from github.com/google/hctr2.
+func TestVectors(t *testing.T) {
+	for _, s := range []string{"AES128", "AES192", "AES256"} {
+		t.Run("HCTR2_"+s, func(t *testing.T) {
+			for i, v := range loadVectors(t, "HCTR2_"+s) {
+				key := unhex(v.Input.Key)
+				tweak := unhex(v.Input.Tweak)
+				plaintext := unhex(v.Plaintext)
+				want := unhex(v.Ciphertext)
+				if got := Encrypt(key, tweak, plaintext); !bytes.Equal(got, want) {
+					t.Fatalf("#%d: (%s): expected %x, got %x",
+						i, v.Description, want, got)
+				}
+				if got := Decrypt(key, tweak, want); !bytes.Equal(got, plaintext) {
+					t.Fatalf("#%d: (%s): expected %x, got %x",
+						i, v.Description, plaintext, got)
+				}
+			}
+		})
+		t.Run("XCTR_"+s, func(t *testing.T) {
+			for i, v := range loadVectors(t, "XCTR_"+s) {
+				src := unhex(v.Plaintext)
+				ks := XCTR(unhex(v.Input.Key), unhex(v.Input.Nonce), len(src))
+				want := unhex(v.Ciphertext)
+				if got := xor(src, ks); !bytes.Equal(got, want) {
+					t.Fatalf("#%d: (%s): expected %x, got %x",
+						i, v.Description, want, got)
+				}
+			}
+		})
+	}
+}
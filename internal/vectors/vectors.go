@@ -0,0 +1,163 @@
+// Package vectors loads and runs HCTR2 test vectors in the JSON
+// format used by github.com/google/hctr2.
+package vectors
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+// Vector is a test vector in the format used by
+// github.com/google/hctr2.
+type Vector struct {
+	Cipher      CipherInfo `json:"cipher"`
+	Description string     `json:"description"`
+	Input       struct {
+		Key   string `json:"key_hex"`
+		Tweak string `json:"tweak_hex"`
+	} `json:"input"`
+	Plaintext  string `json:"plaintext_hex,omitempty"`
+	Ciphertext string `json:"ciphertext_hex,omitempty"`
+}
+
+// Lengths are the block and key sizes of a cipher, in bytes.
+type Lengths struct {
+	Block int `json:"block"`
+	Key   int `json:"key"`
+}
+
+// CipherInfo describes the cipher a Vector is for.
+type CipherInfo struct {
+	Cipher      string `json:"cipher"`
+	BlockCipher struct {
+		Cipher  string  `json:"cipher"`
+		Lengths Lengths `json:"lengths"`
+	} `json:"blockcipher"`
+	Lengths Lengths `json:"lengths"`
+}
+
+// NewCipherInfo returns the CipherInfo for HCTR2-AES with the
+// given key size.
+func NewCipherInfo(keySize int) CipherInfo {
+	var c CipherInfo
+	c.Cipher = "HCTR2"
+	c.BlockCipher.Cipher = "AES"
+	c.BlockCipher.Lengths = Lengths{Block: hctr2.BlockSize, Key: keySize}
+	c.Lengths = Lengths{Block: hctr2.BlockSize, Key: keySize}
+	return c
+}
+
+// Load reads a JSON array of vectors from the file at path.
+func Load(path string) ([]Vector, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var vecs []Vector
+	if err := json.Unmarshal(buf, &vecs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vecs, nil
+}
+
+// Result is the outcome of running a Vector.
+type Result struct {
+	// Backend is the implementation used.
+	Backend hctr2.Backend
+	// Ciphertext is the encryption of the vector's plaintext,
+	// or nil if the vector does not have a plaintext.
+	Ciphertext []byte
+	// Plaintext is the decryption of the vector's ciphertext,
+	// or nil if the vector does not have a ciphertext.
+	Plaintext []byte
+}
+
+// Run encrypts the vector's plaintext and decrypts its
+// ciphertext, whichever are present, with an HCTR2-AES Cipher
+// created with opts.
+//
+// Run returns an error instead of panicking if the vector is
+// malformed or cannot be used with the Cipher.
+func Run(v Vector, opts ...hctr2.Option) (*Result, error) {
+	if v.Cipher.Cipher != "HCTR2" {
+		return nil, fmt.Errorf("unsupported cipher %q", v.Cipher.Cipher)
+	}
+	if c := v.Cipher.BlockCipher.Cipher; c != "AES" {
+		return nil, fmt.Errorf("unsupported block cipher %q", c)
+	}
+	if v.Plaintext == "" && v.Ciphertext == "" {
+		return nil, errors.New("missing plaintext and ciphertext")
+	}
+
+	var key, tweak, plaintext, ciphertext []byte
+	for _, x := range []struct {
+		dst  *[]byte
+		s    string
+		name string
+	}{
+		{&key, v.Input.Key, "key"},
+		{&tweak, v.Input.Tweak, "tweak"},
+		{&plaintext, v.Plaintext, "plaintext"},
+		{&ciphertext, v.Ciphertext, "ciphertext"},
+	} {
+		var err error
+		*x.dst, err = hex.DecodeString(x.s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", x.name, err)
+		}
+	}
+	if v.Plaintext != "" && v.Ciphertext != "" &&
+		len(ciphertext) != len(plaintext) {
+		return nil, fmt.Errorf("ciphertext is %d bytes, but plaintext is %d bytes",
+			len(ciphertext), len(plaintext))
+	}
+
+	c, err := hctr2.NewAES(key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	r := &Result{Backend: c.Implementation()}
+	if v.Plaintext != "" {
+		if err := c.Check(len(plaintext), len(tweak)); err != nil {
+			return nil, err
+		}
+		r.Ciphertext = make([]byte, len(plaintext))
+		c.Encrypt(r.Ciphertext, plaintext, tweak)
+	}
+	if v.Ciphertext != "" {
+		if err := c.Check(len(ciphertext), len(tweak)); err != nil {
+			return nil, err
+		}
+		r.Plaintext = make([]byte, len(ciphertext))
+		c.Decrypt(r.Plaintext, ciphertext, tweak)
+	}
+	return r, nil
+}
+
+// Verify runs a vector that has both a plaintext and
+// a ciphertext and reports whether the results match.
+func Verify(v Vector, opts ...hctr2.Option) (hctr2.Backend, error) {
+	if v.Plaintext == "" || v.Ciphertext == "" {
+		return hctr2.Backend{}, errors.New("missing plaintext or ciphertext")
+	}
+	r, err := Run(v, opts...)
+	if err != nil {
+		return hctr2.Backend{}, err
+	}
+	// Run already decoded both, so these cannot fail.
+	plaintext, _ := hex.DecodeString(v.Plaintext)
+	ciphertext, _ := hex.DecodeString(v.Ciphertext)
+	if !bytes.Equal(r.Ciphertext, ciphertext) {
+		return r.Backend, fmt.Errorf("encrypt: expected %x, got %x", ciphertext, r.Ciphertext)
+	}
+	if !bytes.Equal(r.Plaintext, plaintext) {
+		return r.Backend, fmt.Errorf("decrypt: expected %x, got %x", plaintext, r.Plaintext)
+	}
+	return r.Backend, nil
+}
@@ -0,0 +1,110 @@
+package vectors
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+func loadTestdata(t *testing.T) []Vector {
+	vecs, err := Load(filepath.Join("..", "..", "hctr2test", "testdata", "HCTR2_AES128.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) == 0 {
+		t.Fatal("no vectors")
+	}
+	return vecs
+}
+
+// TestVerify tests Verify with the upstream test vectors.
+func TestVerify(t *testing.T) {
+	for i, v := range loadTestdata(t) {
+		if _, err := Verify(v); err != nil {
+			t.Fatalf("#%d (%s): %v", i, v.Description, err)
+		}
+	}
+}
+
+// TestMalformed tests that Run and Verify return an error
+// instead of panicking for vectors the Cipher cannot use.
+func TestMalformed(t *testing.T) {
+	base := loadTestdata(t)[0]
+	for _, tc := range []struct {
+		name   string
+		modify func(*Vector)
+		is     error
+		errstr string
+	}{
+		{
+			name: "short plaintext",
+			modify: func(v *Vector) {
+				v.Plaintext = v.Plaintext[:30]
+				v.Ciphertext = v.Ciphertext[:30]
+			},
+			is: hctr2.ErrMessageSize,
+		},
+		{
+			name: "short plaintext only",
+			modify: func(v *Vector) {
+				v.Plaintext = v.Plaintext[:30]
+				v.Ciphertext = ""
+			},
+			is: hctr2.ErrMessageSize,
+		},
+		{
+			name: "short ciphertext only",
+			modify: func(v *Vector) {
+				v.Plaintext = ""
+				v.Ciphertext = v.Ciphertext[:30]
+			},
+			is: hctr2.ErrMessageSize,
+		},
+		{
+			name: "mismatched lengths",
+			modify: func(v *Vector) {
+				v.Ciphertext += "00"
+			},
+			errstr: "ciphertext is 17 bytes, but plaintext is 16 bytes",
+		},
+		{
+			name: "invalid hex",
+			modify: func(v *Vector) {
+				v.Input.Tweak = "0"
+			},
+			errstr: "invalid tweak",
+		},
+		{
+			name: "unsupported cipher",
+			modify: func(v *Vector) {
+				v.Cipher.Cipher = "HCTR"
+			},
+			errstr: "unsupported cipher",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			check := func(name string, err error) {
+				t.Helper()
+				if tc.is != nil {
+					if !errors.Is(err, tc.is) {
+						t.Fatalf("%s: expected %v, got %v", name, tc.is, err)
+					}
+				} else if err == nil || !strings.Contains(err.Error(), tc.errstr) {
+					t.Fatalf("%s: expected error containing %q, got %v",
+						name, tc.errstr, err)
+				}
+			}
+			v := base
+			tc.modify(&v)
+			_, err := Run(v)
+			check("Run", err)
+			if v.Plaintext != "" && v.Ciphertext != "" {
+				_, err = Verify(v)
+				check("Verify", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package hctr2
+
+import (
+	"crypto/aes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/ericlagergren/subtle"
+	"golang.org/x/crypto/hkdf"
+)
+
+// kdfLabel domain-separates keys derived by DeriveKey from
+// other uses of the same master secret.
+const kdfLabel = "github.com/ericlagergren/hctr2 key derivation v1"
+
+// minMasterSize is the minimum size of a master secret.
+const minMasterSize = 16
+
+// Hash is the hash function used by HKDF in DeriveKeyHash.
+type Hash uint8
+
+const (
+	// SHA256 is HKDF-SHA-256.
+	SHA256 Hash = iota + 1
+	// SHA512 is HKDF-SHA-512.
+	SHA512
+)
+
+func (h Hash) String() string {
+	switch h {
+	case SHA256:
+		return "SHA-256"
+	case SHA512:
+		return "SHA-512"
+	default:
+		return fmt.Sprintf("Hash(%d)", uint8(h))
+	}
+}
+
+func (h Hash) new() (func() hash.Hash, error) {
+	switch h {
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("hctr2: unknown hash: %v", h)
+	}
+}
+
+// DeriveKey derives a keySize-byte AES key for NewAES from
+// a master secret using HKDF-SHA-256.
+//
+// It is equivalent to DeriveKeyHash(SHA256, master, keySize,
+// path...).
+//
+// The key is bound to each element of path, in order. For
+// example, the key for a file on a volume might be derived
+// with
+//
+//	DeriveKey(master, 32, volumeID, fileID)
+//
+// Each element is length-prefixed, so distinct paths always
+// produce distinct keys.
+//
+// The master secret must be at least 16 bytes of uniformly
+// random data. It must not be a password.
+func DeriveKey(master []byte, keySize int, path ...[]byte) ([]byte, error) {
+	return DeriveKeyHash(SHA256, master, keySize, path...)
+}
+
+// DeriveKeyHash is like DeriveKey, but uses HKDF with the hash
+// function h.
+func DeriveKeyHash(h Hash, master []byte, keySize int, path ...[]byte) ([]byte, error) {
+	fn, err := h.new()
+	if err != nil {
+		return nil, err
+	}
+	switch keySize {
+	case 16, 24, 32:
+		// OK
+	default:
+		return nil, aes.KeySizeError(keySize)
+	}
+	if len(master) < minMasterSize {
+		return nil, errors.New("hctr2: master secret is too short")
+	}
+
+	// info = label || 0x00 || u16(keySize)
+	//        || u64(len(path[0])) || path[0] || ...
+	info := make([]byte, 0, len(kdfLabel)+3+(8*len(path)))
+	info = append(info, kdfLabel...)
+	info = append(info, 0, byte(keySize>>8), byte(keySize))
+	for _, p := range path {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(p)))
+		info = append(info, n[:]...)
+		info = append(info, p...)
+	}
+
+	key := make([]byte, keySize)
+	r := hkdf.New(fn, master, nil, info)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewFromMasterKey creates a HCTR2 cipher using AES with a key
+// derived from a master secret.
+//
+// It is equivalent to calling NewAES with the result of
+//
+//	DeriveKey(master, keySize, context)
+//
+// The key is derived with HKDF-SHA-256. To use HKDF-SHA-512,
+// call NewAES with the result of DeriveKeyHash.
+func NewFromMasterKey(master, context []byte, keySize int, opts ...Option) (*Cipher, error) {
+	key, err := DeriveKey(master, keySize, context)
+	if err != nil {
+		return nil, err
+	}
+	defer subtle.Wipe(key)
+	return NewAES(key, opts...)
+}
@@ -0,0 +1,111 @@
+package hctr2
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestDeriveKey tests DeriveKey and DeriveKeyHash against known
+// answers computed with an independent HKDF implementation.
+func TestDeriveKey(t *testing.T) {
+	master := make([]byte, 32)
+	for i := range master {
+		master[i] = byte(i)
+	}
+	path := [][]byte{[]byte("volume"), []byte("file")}
+
+	want := unhex("f7122f3c5732c11bc6ba97fd9ee8cb0f3066c09feff899bdc474cb9a70da2dfb")
+	got, err := DeriveKey(master, 32, path...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %x, got %x", want, got)
+	}
+
+	for _, tc := range []struct {
+		h    Hash
+		want string
+	}{
+		{SHA256, "f7122f3c5732c11bc6ba97fd9ee8cb0f3066c09feff899bdc474cb9a70da2dfb"},
+		{SHA512, "ea2cd8cf8c8a6e251f6993c027b47ff675d46f3cbb7121edc59154b38adc2afd"},
+	} {
+		want := unhex(tc.want)
+		got, err := DeriveKeyHash(tc.h, master, 32, path...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%v: expected %x, got %x", tc.h, want, got)
+		}
+	}
+	if _, err := DeriveKeyHash(0, master, 32); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// TestDeriveKeySeparation tests that distinct paths and key
+// sizes produce distinct keys.
+func TestDeriveKeySeparation(t *testing.T) {
+	master := randbuf(32)
+	seen := make(map[string][][]byte)
+	for _, path := range [][][]byte{
+		nil,
+		{{}},
+		{{}, {}},
+		{[]byte("ab"), []byte("c")},
+		{[]byte("a"), []byte("bc")},
+		{[]byte("abc")},
+	} {
+		for _, n := range testKeySizes {
+			key, err := DeriveKey(master, n, path...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(key) != n {
+				t.Fatalf("expected %d byte key, got %d", n, len(key))
+			}
+			if prev, ok := seen[string(key[:16])]; ok {
+				t.Fatalf("%q and %q derived the same key", prev, path)
+			}
+			seen[string(key[:16])] = path
+		}
+	}
+}
+
+func TestDeriveKeyErrors(t *testing.T) {
+	if _, err := DeriveKey(randbuf(32), 20); err == nil {
+		t.Fatal("expected an error for an invalid key size")
+	}
+	if _, err := DeriveKey(randbuf(minMasterSize-1), 32); err == nil {
+		t.Fatal("expected an error for a short master secret")
+	}
+}
+
+// TestNewFromMasterKey tests that NewFromMasterKey is
+// equivalent to NewAES with a derived key.
+func TestNewFromMasterKey(t *testing.T) {
+	master := randbuf(32)
+	context := []byte("tenant 42")
+	key, err := DeriveKey(master, 16, context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAES(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewFromMasterKey(master, context, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := randbuf(100)
+	tweak := randbuf(16)
+	want := make([]byte, len(plaintext))
+	a.Encrypt(want, plaintext, tweak)
+	got := make([]byte, len(plaintext))
+	b.Encrypt(got, plaintext, tweak)
+	if !bytes.Equal(want, got) {
+		t.Fatalf("expected %x, got %x", want, got)
+	}
+}
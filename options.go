@@ -0,0 +1,155 @@
+package hctr2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxTweakSize is the maximum length in bytes of a tweak.
+//
+// The hash encodes the length of the tweak in bits as
+// 2*8*|T| + 3, which must fit in 64 bits. On 32-bit platforms,
+// every tweak is shorter than MaxTweakSize.
+//
+// There is no corresponding limit on the length of a message:
+// XCTR uses a 64-bit block counter, so the keystream does not
+// repeat for messages shorter than 2^68 bytes, which is longer
+// than any slice.
+const MaxTweakSize = 1<<60 - 1
+
+var (
+	// ErrMessageSize is returned by Check when the length of
+	// a message is outside of the limits set by MinSize and
+	// MaxSize.
+	ErrMessageSize = errors.New("hctr2: invalid message size")
+	// ErrTweakSize is returned by Check when the length of
+	// a tweak is larger than MaxTweakSize or does not match the
+	// length set by TweakSize.
+	ErrTweakSize = errors.New("hctr2: invalid tweak size")
+)
+
+// Option configures a Cipher.
+type Option func(*config)
+
+type config struct {
+	// generic forces NewAES to use the generic backend.
+	generic bool
+	// minSize is the minimum message size.
+	minSize int
+	// maxSize is the maximum message size, or zero if there is
+	// no maximum.
+	maxSize int
+	// tweakSize is the required tweak size, or -1 if the tweak
+	// can be any size.
+	tweakSize int
+	// zeroize clears intermediate values after each message.
+	zeroize bool
+	// short enables messages shorter than BlockSize.
+	short bool
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		tweakSize: -1,
+	}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	if cfg.minSize == 0 {
+		cfg.minSize = BlockSize
+		if cfg.short {
+			cfg.minSize = 1
+		}
+	}
+	return cfg
+}
+
+func (c config) validate() error {
+	min := BlockSize
+	if c.short {
+		min = 1
+	}
+	if c.minSize < min {
+		return fmt.Errorf("hctr2: minimum message size must be at least %d: %d",
+			min, c.minSize)
+	}
+	if c.maxSize != 0 && c.maxSize < c.minSize {
+		return fmt.Errorf("hctr2: maximum message size %d is smaller than minimum %d",
+			c.maxSize, c.minSize)
+	}
+	if c.tweakSize < -1 {
+		return fmt.Errorf("hctr2: invalid tweak size: %d", c.tweakSize)
+	}
+	return nil
+}
+
+// Generic forces NewAES to use crypto/aes and the generic XCTR
+// implementation, even if assembly is supported.
+//
+// It has no effect on New.
+func Generic() Option {
+	return func(c *config) {
+		c.generic = true
+	}
+}
+
+// MinSize sets the minimum length of a message.
+//
+// For example, disk encryption might require every message to
+// be at least one sector long.
+//
+// The minimum must be at least BlockSize, which is also the
+// default. If ShortMessages is used, the minimum must be at
+// least one and defaults to one.
+func MinSize(n int) Option {
+	return func(c *config) {
+		c.minSize = n
+	}
+}
+
+// MaxSize sets the maximum length of a message.
+//
+// By default, there is no maximum.
+func MaxSize(n int) Option {
+	return func(c *config) {
+		c.maxSize = n
+	}
+}
+
+// TweakSize requires every tweak to be exactly n bytes long.
+//
+// By default, the tweak can be any length.
+func TweakSize(n int) Option {
+	return func(c *config) {
+		c.tweakSize = n
+	}
+}
+
+// Zeroize clears intermediate values derived from each message
+// after Encrypt and Decrypt return.
+//
+// It does not clear the key schedule or the hash key, which
+// are needed for subsequent messages.
+func Zeroize() Option {
+	return func(c *config) {
+		c.zeroize = true
+	}
+}
+
+// ShortMessages allows messages between 1 and BlockSize-1 bytes
+// long.
+//
+// HCTR2 is not defined for messages shorter than BlockSize, so
+// short messages are encrypted with a separate length-preserving
+// construction: a ten-round balanced Feistel network over the
+// bits of the message, keyed by the same block cipher and
+// tweak. See Cipher.Encrypt for details.
+//
+// Small domains are inherently weak: an adversary that can
+// obtain encryptions can enumerate every one-byte message, for
+// example.
+func ShortMessages() Option {
+	return func(c *config) {
+		c.short = true
+	}
+}
@@ -0,0 +1,142 @@
+package packet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+func randbuf(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+func TestRoundTrip(t *testing.T) {
+	p, err := New(randbuf(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n <= 1500; n++ {
+		pkt := randbuf(n + 8)
+		want := dup(pkt)
+		if err := p.SealPacket(pkt, 8); err != nil {
+			t.Fatalf("%d: %v", n, err)
+		}
+		if !bytes.Equal(pkt[:8], want[:8]) {
+			t.Fatalf("%d: header was modified", n)
+		}
+		if n > 0 && bytes.Equal(pkt, want) {
+			t.Fatalf("%d: payload was not encrypted", n)
+		}
+		if err := p.OpenPacket(pkt, 8); err != nil {
+			t.Fatalf("%d: %v", n, err)
+		}
+		if !bytes.Equal(pkt, want) {
+			t.Fatalf("%d: expected %x, got %x", n, want, pkt)
+		}
+	}
+}
+
+// TestHeaderTweak tests that the ciphertext depends on the
+// header.
+func TestHeaderTweak(t *testing.T) {
+	p, err := New(randbuf(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, hctr2.BlockSize - 1, hctr2.BlockSize, 100} {
+		payload := randbuf(n)
+		a, b := dup(payload), dup(payload)
+		if err := p.Seal([]byte{0, 0, 0, 1}, a); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Seal([]byte{0, 0, 0, 2}, b); err != nil {
+			t.Fatal(err)
+		}
+		if n > 2 && bytes.Equal(a, b) {
+			t.Fatalf("%d: ciphertext does not depend on the header", n)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	p, err := New(randbuf(16), hctr2.TweakSize(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Seal(make([]byte, 5), randbuf(20)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := p.SealPacket(randbuf(10), 11); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	p, err := New(randbuf(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each goroutine uses a different header length so
+			// that the cached tweak state differs.
+			hdr := 4 + i
+			for seq := uint32(0); seq < 200; seq++ {
+				pkt := randbuf(hdr + int(seq)%64)
+				binary.BigEndian.PutUint32(pkt, seq)
+				want := dup(pkt)
+				if err := p.SealPacket(pkt, hdr); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := p.OpenPacket(pkt, hdr); err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(pkt, want) {
+					t.Errorf("%d: mismatch", i)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestConcurrentClone tests that creating a new pooled cipher
+// does not race with a pooled cipher that is in use.
+func TestConcurrentClone(t *testing.T) {
+	p, err := New(randbuf(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := p.pool.Get()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			buf := randbuf(hctr2.BlockSize + i)
+			c.Encrypt(buf, buf, randbuf(i))
+		}
+	}()
+	// The pool is empty, so each Get creates a new cipher.
+	for i := 0; i < 100; i++ {
+		p.pool.Get()
+	}
+	<-done
+}
+
+func dup(p []byte) []byte {
+	return append([]byte(nil), p...)
+}
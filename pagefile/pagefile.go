@@ -0,0 +1,222 @@
+// Package pagefile encrypts page-oriented files with HCTR2.
+//
+// A File wraps an io.ReaderAt and io.WriterAt, like an
+// *os.File, and encrypts each fixed-size page separately. The
+// encrypted file is exactly the same size as the plaintext
+// file, so it can be used underneath storage engines whose page
+// layout cannot change, like B+tree key-value stores.
+//
+// The storage engine must perform all I/O through the File.
+// Engines that memory-map the underlying file (for example,
+// bbolt's read path) bypass the File and see ciphertext.
+//
+// HCTR2 does not authenticate the data. Modifying a page's
+// ciphertext randomizes the corresponding plaintext page but is
+// not detected.
+package pagefile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ericlagergren/hctr2"
+	"github.com/ericlagergren/hctr2/internal/pool"
+)
+
+// Storage is the file being encrypted.
+type Storage interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// TweakFunc appends the tweak for page pgid to dst and returns
+// the resulting slice.
+//
+// The tweak can only depend on information that is available
+// when the page is read back, like the page ID or a per-file
+// salt. For example, a transaction ID can only be part of the
+// tweak if the storage engine can recover it before reading the
+// page.
+type TweakFunc func(dst []byte, pgid uint64) []byte
+
+// PageLE64 is a TweakFunc that encodes the page ID as a 64-bit
+// little-endian integer.
+func PageLE64(dst []byte, pgid uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], pgid)
+	return append(dst, b[:]...)
+}
+
+// File encrypts pages of an underlying Storage.
+//
+// A File is safe for concurrent use, but concurrent writes that
+// modify part of the same page must be serialized by the
+// caller.
+type File struct {
+	s        Storage
+	pageSize int
+	tweak    TweakFunc
+	ciphers  *pool.Pool
+	bufs     sync.Pool
+}
+
+var (
+	_ io.ReaderAt = (*File)(nil)
+	_ io.WriterAt = (*File)(nil)
+)
+
+// state is the per-goroutine state used by File.
+type state struct {
+	c     *hctr2.Cipher
+	page  []byte
+	tweak []byte
+}
+
+// New creates a File that encrypts s with c.
+//
+// The pageSize must be at least hctr2.BlockSize. If tweak is
+// nil, PageLE64 is used. New returns an error if c cannot
+// encrypt pageSize-byte pages with the tweak for page 0.
+//
+// c must not be used after calling New.
+func New(s Storage, c *hctr2.Cipher, pageSize int, tweak TweakFunc) (*File, error) {
+	if pageSize < hctr2.BlockSize {
+		return nil, fmt.Errorf("pagefile: invalid page size: %d", pageSize)
+	}
+	if tweak == nil {
+		tweak = PageLE64
+	}
+	// Check the page geometry now instead of letting Encrypt or
+	// Decrypt panic in ReadAt or WriteAt.
+	if err := c.Check(pageSize, len(tweak(nil, 0))); err != nil {
+		return nil, fmt.Errorf("pagefile: %w", err)
+	}
+	f := &File{
+		s:        s,
+		pageSize: pageSize,
+		tweak:    tweak,
+		ciphers:  pool.New(c),
+	}
+	f.bufs.New = func() interface{} {
+		return &state{
+			page: make([]byte, pageSize),
+		}
+	}
+	return f, nil
+}
+
+// get returns the state for one ReadAt or WriteAt call.
+func (f *File) get() *state {
+	st := f.bufs.Get().(*state)
+	st.c = f.ciphers.Get()
+	return st
+}
+
+// put returns st to the pool.
+func (f *File) put(st *state) {
+	f.ciphers.Put(st.c)
+	st.c = nil
+	f.bufs.Put(st)
+}
+
+// PageSize returns the size of each page.
+func (f *File) PageSize() int {
+	return f.pageSize
+}
+
+// ReadAt implements io.ReaderAt.
+//
+// The underlying Storage must contain whole pages. Reads do not
+// need to be page aligned.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("pagefile: negative offset")
+	}
+	st := f.get()
+	defer f.put(st)
+
+	ps := int64(f.pageSize)
+	n := 0
+	for len(p) > 0 {
+		pgid := uint64(off / ps)
+		lo := int(off % ps)
+		var m int
+		if lo == 0 && len(p) >= f.pageSize {
+			// Decrypt directly into p.
+			if err := f.readPage(st, p[:f.pageSize], pgid); err != nil {
+				return n, err
+			}
+			m = f.pageSize
+		} else {
+			if err := f.readPage(st, st.page, pgid); err != nil {
+				return n, err
+			}
+			m = copy(p, st.page[lo:])
+		}
+		p = p[m:]
+		off += int64(m)
+		n += m
+	}
+	return n, nil
+}
+
+// readPage reads and decrypts page pgid into dst.
+func (f *File) readPage(st *state, dst []byte, pgid uint64) error {
+	off := int64(pgid) * int64(f.pageSize)
+	m, err := f.s.ReadAt(dst, off)
+	if m < len(dst) {
+		if err == nil || err == io.EOF {
+			if m == 0 {
+				return io.EOF
+			}
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	st.tweak = f.tweak(st.tweak[:0], pgid)
+	st.c.Decrypt(dst, dst, st.tweak)
+	return nil
+}
+
+// WriteAt implements io.WriterAt.
+//
+// Writes that cover part of a page read the rest of the page
+// from the underlying Storage. Parts of a page past the end of
+// the Storage are filled with zeros.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("pagefile: negative offset")
+	}
+	st := f.get()
+	defer f.put(st)
+
+	ps := int64(f.pageSize)
+	n := 0
+	for len(p) > 0 {
+		pgid := uint64(off / ps)
+		lo := int(off % ps)
+		if lo != 0 || len(p) < f.pageSize {
+			err := f.readPage(st, st.page, pgid)
+			if err == io.EOF {
+				for i := range st.page {
+					st.page[i] = 0
+				}
+			} else if err != nil {
+				return n, err
+			}
+		}
+		m := copy(st.page[lo:], p)
+		st.tweak = f.tweak(st.tweak[:0], pgid)
+		st.c.Encrypt(st.page, st.page, st.tweak)
+		if _, err := f.s.WriteAt(st.page, int64(pgid)*ps); err != nil {
+			return n, err
+		}
+		p = p[m:]
+		off += int64(m)
+		n += m
+	}
+	return n, nil
+}
@@ -0,0 +1,208 @@
+package pagefile
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	mrand "math/rand"
+	"sync"
+	"testing"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+// memStorage is an in-memory Storage.
+type memStorage struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (s *memStorage) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if off >= int64(len(s.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, s.buf[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (s *memStorage) WriteAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if end := int(off) + len(p); end > len(s.buf) {
+		s.buf = append(s.buf, make([]byte, end-len(s.buf))...)
+	}
+	return copy(s.buf[off:], p), nil
+}
+
+func randbuf(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+func newFile(t *testing.T, s Storage, pageSize int, tweak TweakFunc) *File {
+	c, err := hctr2.NewAES(randbuf(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := New(s, c, pageSize, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// TestRandomAccess compares a File against an in-memory buffer
+// after random reads and writes.
+func TestRandomAccess(t *testing.T) {
+	const pageSize = 256
+	s := &memStorage{}
+	f := newFile(t, s, pageSize, nil)
+
+	rng := mrand.New(mrand.NewSource(1))
+	var want []byte
+	for i := 0; i < 1000; i++ {
+		if rng.Intn(2) == 0 {
+			off := rng.Intn(len(want) + pageSize)
+			p := randbuf(rng.Intn(3 * pageSize))
+			if _, err := f.WriteAt(p, int64(off)); err != nil {
+				t.Fatal(err)
+			}
+			if end := off + len(p); end > len(want) {
+				want = append(want, make([]byte, end-len(want))...)
+			}
+			copy(want[off:], p)
+		} else {
+			off := rng.Intn(len(want) + 1)
+			got := make([]byte, rng.Intn(3*pageSize))
+			n, err := f.ReadAt(got, int64(off))
+			if n < len(got) && err == nil {
+				t.Fatalf("#%d: short read without an error", i)
+			}
+			if !bytes.Equal(got[:n], want[off:off+n]) {
+				t.Fatalf("#%d: mismatch at offset %d", i, off)
+			}
+		}
+		if len(s.buf)%pageSize != 0 {
+			t.Fatalf("#%d: storage is not a whole number of pages: %d",
+				i, len(s.buf))
+		}
+	}
+}
+
+// TestTweak tests that each page is encrypted with its own
+// tweak.
+func TestTweak(t *testing.T) {
+	const pageSize = 64
+	salt := randbuf(16)
+	tweak := func(dst []byte, pgid uint64) []byte {
+		dst = append(dst, salt...)
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], pgid)
+		return append(dst, b[:]...)
+	}
+	s := &memStorage{}
+	f := newFile(t, s, pageSize, tweak)
+
+	data := make([]byte, 4*pageSize)
+	if _, err := f.WriteAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.buf) != len(data) {
+		t.Fatalf("expected %d bytes, got %d", len(data), len(s.buf))
+	}
+	for i := 0; i < 4; i++ {
+		for j := i + 1; j < 4; j++ {
+			if bytes.Equal(s.buf[i*pageSize:(i+1)*pageSize],
+				s.buf[j*pageSize:(j+1)*pageSize]) {
+				t.Fatalf("pages %d and %d are identical", i, j)
+			}
+		}
+	}
+}
+
+// TestConcurrent tests concurrent access to different pages.
+func TestConcurrent(t *testing.T) {
+	const pageSize = 512
+	s := &memStorage{}
+	f := newFile(t, s, pageSize, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(pgid int) {
+			defer wg.Done()
+			want := randbuf(pageSize)
+			off := int64(pgid * pageSize)
+			for k := 0; k < 100; k++ {
+				if _, err := f.WriteAt(want, off); err != nil {
+					t.Error(err)
+					return
+				}
+				got := make([]byte, pageSize)
+				if _, err := f.ReadAt(got, off); err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("page %d: mismatch", pgid)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestConcurrentClone tests that creating a new pooled cipher
+// does not race with a pooled cipher that is in use.
+func TestConcurrentClone(t *testing.T) {
+	f := newFile(t, &memStorage{}, 512, nil)
+	c := f.ciphers.Get()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			buf := randbuf(hctr2.BlockSize + i)
+			c.Encrypt(buf, buf, randbuf(i))
+		}
+	}()
+	// The pool is empty, so each Get creates a new cipher.
+	for i := 0; i < 100; i++ {
+		f.ciphers.Get()
+	}
+	<-done
+}
+
+// TestCheck tests that New rejects ciphers that cannot encrypt
+// the pages.
+func TestCheck(t *testing.T) {
+	for i, opt := range []hctr2.Option{
+		hctr2.MaxSize(256),
+		hctr2.MinSize(1024),
+		hctr2.TweakSize(16),
+	} {
+		c, err := hctr2.NewAES(randbuf(32), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := New(&memStorage{}, c, 512, nil); err == nil {
+			t.Fatalf("#%d: expected an error", i)
+		}
+	}
+	c, err := hctr2.NewAES(randbuf(32), hctr2.TweakSize(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(&memStorage{}, c, 512, nil); err != nil {
+		t.Fatal(err)
+	}
+}
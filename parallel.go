@@ -0,0 +1,228 @@
+package hctr2
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/ericlagergren/polyval"
+)
+
+// minChunkBlocks is the smallest number of blocks that
+// EncryptParallel and DecryptParallel give to a goroutine.
+//
+// It must be a power of two.
+const minChunkBlocks = 512
+
+// EncryptParallel is like Encrypt, but splits the work among up
+// to workers goroutines.
+//
+// The result is identical to Encrypt. Messages that are too
+// short to benefit are encrypted on the calling goroutine.
+//
+// The underlying block cipher must be safe for concurrent use.
+// Block ciphers created by crypto/aes and NewAES are safe for
+// concurrent use.
+func (c *Cipher) EncryptParallel(ciphertext, plaintext, tweak []byte, workers int) {
+	c.encrypt(ciphertext, plaintext, tweak, workers)
+}
+
+// DecryptParallel is like Decrypt, but splits the work among up
+// to workers goroutines.
+//
+// The result is identical to Decrypt. Messages that are too
+// short to benefit are decrypted on the calling goroutine.
+//
+// The underlying block cipher must be safe for concurrent use.
+// Block ciphers created by crypto/aes and NewAES are safe for
+// concurrent use.
+func (c *Cipher) DecryptParallel(plaintext, ciphertext, tweak []byte, workers int) {
+	c.decrypt(plaintext, ciphertext, tweak, workers)
+}
+
+// chunkBlocks returns the number of blocks in each chunk when
+// n blocks are split among workers goroutines, or zero if n
+// should not be split.
+//
+// The result is always a power of two.
+func chunkBlocks(n, workers int) int {
+	if workers < 2 || n < 2*minChunkBlocks {
+		return 0
+	}
+	// Use a few chunks per goroutine so that the last, short
+	// chunk does not leave the others idle.
+	m := minChunkBlocks
+	for m*4*workers < n {
+		m *= 2
+	}
+	return m
+}
+
+// runParallel calls fn(0), fn(1), ..., fn(n-1) using up to
+// workers goroutines.
+func runParallel(workers, n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < n; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < n; i += workers {
+				fn(i)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// polyhashParallel is polyhash split among workers goroutines.
+//
+// POLYVAL over X_1 || ... || X_s starting from state y is
+//
+//	y•h^s ⊕ X_1•h^s ⊕ ... ⊕ X_s•h
+//
+// so each chunk of m blocks can be hashed from the zero state
+// and the results combined with Horner's rule:
+//
+//	y ← y•h^m ⊕ POLYVAL(h, chunk)
+//
+// Unlike polyhash, p is not updated.
+func (c *Cipher) polyhashParallel(p *polyval.Polyval, sum *[BlockSize]byte, src []byte, workers int) {
+	nblocks := (len(src) + BlockSize - 1) / BlockSize
+	m := chunkBlocks(nblocks, workers)
+	if m == 0 {
+		polyhash(p, sum, src)
+		return
+	}
+	nchunks := (nblocks + m - 1) / m
+
+	sums := make([][BlockSize]byte, nchunks)
+	runParallel(workers, nchunks, func(i int) {
+		chunk := src[i*m*BlockSize:]
+		if len(chunk) > m*BlockSize {
+			chunk = chunk[:m*BlockSize]
+		}
+		q := *p
+		q.Reset()
+		polyhash(&q, &sums[i], chunk)
+	})
+
+	// p does not expose its key, so recompute it. Every
+	// POLYVAL instance passed to polyhashParallel is keyed with
+	// h ← Ek(bin(0)).
+	var h, y [BlockSize]byte
+	c.block.Encrypt(h[:], h[:])
+	p.Sum(y[:0])
+
+	hm := pow(&h, m)
+	k := hm
+	for i := range sums {
+		if i == nchunks-1 {
+			if r := nblocks - i*m; r != m {
+				k = pow(&h, r)
+			}
+		}
+		y = dot(&y, &k)
+		xorBlock(&y, &y, &sums[i])
+	}
+	*sum = y
+
+	if c.cfg.zeroize {
+		for _, b := range []*[BlockSize]byte{&h, &hm, &k} {
+			*b = [BlockSize]byte{}
+		}
+		for i := range sums {
+			sums[i] = [BlockSize]byte{}
+		}
+	}
+}
+
+// dot returns a•b, the POLYVAL product of a and b.
+func dot(a, b *[BlockSize]byte) [BlockSize]byte {
+	// POLYVAL(b, a) = (0 ⊕ a)•b
+	var p polyval.Polyval
+	if err := p.Init(b[:]); err != nil {
+		// b is a power of h, which is never zero.
+		panic(err)
+	}
+	p.Update(a[:])
+	var z [BlockSize]byte
+	p.Sum(z[:0])
+	return z
+}
+
+// pow returns h^e for e >= 1 using POLYVAL multiplication.
+func pow(h *[BlockSize]byte, e int) [BlockSize]byte {
+	n := 0
+	for x := e; x > 1; x >>= 1 {
+		n++
+	}
+	z := *h
+	for i := n - 1; i >= 0; i-- {
+		z = dot(&z, &z)
+		if e>>i&1 == 1 {
+			z = dot(&z, h)
+		}
+	}
+	return z
+}
+
+// xctrParallel is Cipher.xctr split among workers goroutines.
+//
+// XCTR XORs the counter into the nonce instead of adding it, so
+// a chunk cannot simply start with a different counter.
+// Instead, chunk i covers the counters [i*m, (i+1)*m), where m
+// is a power of two. Every counter in the chunk is i*m | j for
+// some j < m, so
+//
+//	S ⊕ bin(i*m + j) = (S ⊕ bin(i*m)) ⊕ bin(j)
+//
+// and the chunk is XCTR with the nonce S ⊕ bin(i*m), except for
+// its first block (j = 0), which is computed separately. XCTR
+// counters start at one, so the first chunk is XCTR with the
+// nonce S.
+func (c *Cipher) xctrParallel(dst, src []byte, nonce *[BlockSize]byte, workers int) {
+	nblocks := (len(src) + BlockSize - 1) / BlockSize
+	m := chunkBlocks(nblocks, workers)
+	if m == 0 {
+		c.xctr(dst, src, nonce)
+		return
+	}
+	// The counter for block k of src is k+1.
+	nchunks := nblocks/m + 1
+
+	runParallel(workers, nchunks, func(i int) {
+		first, last := i*m, (i+1)*m
+		if i == 0 {
+			first = 1
+		}
+		if last > nblocks+1 {
+			last = nblocks + 1
+		}
+		start := (first - 1) * BlockSize
+		end := (last - 1) * BlockSize
+		if end > len(src) {
+			end = len(src)
+		}
+		d, s := dst[start:end], src[start:end]
+
+		// x only uses the block cipher and its own scratch
+		// space, so it is safe to use concurrently with c.
+		x := Cipher{block: c.block}
+		if i == 0 {
+			x.xctr(d, s, nonce)
+			return
+		}
+
+		var n [BlockSize]byte
+		binary.LittleEndian.PutUint64(n[0:8], uint64(i*m))
+		xorBlock(&n, &n, nonce)
+
+		var ks [BlockSize]byte
+		c.block.Encrypt(ks[:], n[:])
+		k := len(s)
+		if k > BlockSize {
+			k = BlockSize
+		}
+		xor(d, ks[:], s, k)
+		x.xctr(d[k:], s[k:], &n)
+	})
+}
@@ -0,0 +1,85 @@
+package hctr2
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// TestEncryptParallel tests that EncryptParallel and
+// DecryptParallel compute the same result as Encrypt and
+// Decrypt.
+func TestEncryptParallel(t *testing.T) {
+	test := func(t *testing.T, opts ...Option) {
+		c, err := NewAES(randbuf(32), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sizes []int
+		for _, blocks := range []int{
+			1,
+			2 * minChunkBlocks,
+			4 * minChunkBlocks,
+			33 * minChunkBlocks,
+		} {
+			for _, delta := range []int{-BlockSize - 1, -1, 0, 1, BlockSize, BlockSize + 1} {
+				if n := blocks*BlockSize + delta; n >= BlockSize {
+					sizes = append(sizes, n)
+				}
+			}
+		}
+		for _, n := range sizes {
+			plaintext := randbuf(n)
+			tweak := randbuf(n % 33)
+			want := make([]byte, n)
+			c.Encrypt(want, plaintext, tweak)
+			for _, workers := range []int{0, 1, 2, 3, 8} {
+				got := make([]byte, n)
+				c.EncryptParallel(got, plaintext, tweak, workers)
+				if !bytes.Equal(got, want) {
+					t.Fatalf("%d, %d: encrypt mismatch", n, workers)
+				}
+				c.DecryptParallel(got, got, tweak, workers)
+				if !bytes.Equal(got, plaintext) {
+					t.Fatalf("%d, %d: decrypt mismatch", n, workers)
+				}
+			}
+		}
+	}
+	runTests(t, func(t *testing.T) {
+		test(t)
+	})
+	runTests(t, func(t *testing.T) {
+		test(t, Zeroize())
+	})
+}
+
+// TestPow tests pow against repeated multiplication.
+func TestPow(t *testing.T) {
+	var h [BlockSize]byte
+	copy(h[:], randbuf(BlockSize))
+	want := h
+	for e := 1; e < 100; e++ {
+		if got := pow(&h, e); got != want {
+			t.Fatalf("%d: expected %x, got %x", e, want, got)
+		}
+		want = dot(&want, &h)
+	}
+}
+
+func BenchmarkEncryptParallel(b *testing.B) {
+	c, err := NewAES(randbuf(32))
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := randbuf(4 << 20)
+	tweak := randbuf(16)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprint(workers), func(b *testing.B) {
+			b.SetBytes(int64(len(buf)))
+			for i := 0; i < b.N; i++ {
+				c.EncryptParallel(buf, buf, tweak, workers)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package hctr2
+
+import (
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericlagergren/subtle"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF is a password-based key derivation function.
+type KDF uint8
+
+const (
+	// Argon2id is Argon2id as defined in RFC 9106.
+	Argon2id KDF = iota + 1
+	// Scrypt is scrypt as defined in RFC 7914.
+	Scrypt
+)
+
+func (k KDF) String() string {
+	switch k {
+	case Argon2id:
+		return "argon2id"
+	case Scrypt:
+		return "scrypt"
+	default:
+		return fmt.Sprintf("KDF(%d)", uint8(k))
+	}
+}
+
+// MinSaltSize is the minimum size of a passphrase salt.
+const MinSaltSize = 16
+
+// MaxKDFMemory is the maximum amount of memory in bytes that
+// KDFParams can require.
+//
+// It bounds the memory used by parameters read from untrusted
+// input, like a file header. It allows the 2 GiB Argon2id
+// parameters recommended by RFC 9106.
+const MaxKDFMemory = 2 << 30
+
+// KDFParams configures a password-based key derivation
+// function.
+//
+// KDFParams implements encoding.TextMarshaler and
+// encoding.TextUnmarshaler, so parameters can be stored
+// alongside the salt. The text forms are
+//
+//	argon2id$m=<memory>,t=<time>,p=<threads>,k=<key size>
+//	scrypt$ln=<log2(N)>,r=<r>,p=<p>,k=<key size>
+type KDFParams struct {
+	// KDF is the key derivation function.
+	KDF KDF
+	// KeySize is the size in bytes of the derived AES key: 16,
+	// 24, or 32.
+	KeySize int
+
+	// Time is the number of Argon2id passes.
+	Time uint32
+	// Memory is the Argon2id memory size in KiB.
+	Memory uint32
+	// Threads is the Argon2id degree of parallelism.
+	Threads uint8
+
+	// LogN is the base-2 logarithm of the scrypt cost
+	// parameter N.
+	LogN uint8
+	// R is the scrypt block size parameter.
+	R int
+	// P is the scrypt parallelization parameter.
+	P int
+}
+
+// DefaultKDFParams returns the Argon2id parameters recommended
+// by RFC 9106 for memory-constrained environments with
+// a 256-bit key.
+func DefaultKDFParams() KDFParams {
+	return KDFParams{
+		KDF:     Argon2id,
+		KeySize: 32,
+		Time:    3,
+		Memory:  64 * 1024,
+		Threads: 4,
+	}
+}
+
+// DefaultScryptParams returns the interactive scrypt parameters
+// recommended by the scrypt package with a 256-bit key.
+func DefaultScryptParams() KDFParams {
+	return KDFParams{
+		KDF:     Scrypt,
+		KeySize: 32,
+		LogN:    15,
+		R:       8,
+		P:       1,
+	}
+}
+
+func (p KDFParams) validate() error {
+	switch p.KeySize {
+	case 16, 24, 32:
+		// OK
+	default:
+		return aes.KeySizeError(p.KeySize)
+	}
+	switch p.KDF {
+	case Argon2id:
+		if p.Time < 1 {
+			return errors.New("hctr2: argon2id time must be at least 1")
+		}
+		if p.Threads < 1 {
+			return errors.New("hctr2: argon2id threads must be at least 1")
+		}
+		if p.Memory < 8*uint32(p.Threads) {
+			return errors.New("hctr2: argon2id memory must be at least 8*threads KiB")
+		}
+		if uint64(p.Memory)*1024 > MaxKDFMemory {
+			return fmt.Errorf("hctr2: argon2id memory is larger than %d KiB",
+				uint64(MaxKDFMemory/1024))
+		}
+	case Scrypt:
+		if p.LogN < 1 || p.LogN > 62 {
+			return fmt.Errorf("hctr2: invalid scrypt log2(N): %d", p.LogN)
+		}
+		if p.R < 1 || p.P < 1 || uint64(p.R)*uint64(p.P) >= 1<<30 {
+			return errors.New("hctr2: invalid scrypt parameters")
+		}
+		// scrypt uses 128*r*N bytes for V and 128*r*p bytes for
+		// B.
+		if uint64(p.R) > MaxKDFMemory/128>>p.LogN ||
+			uint64(p.R)*uint64(p.P) > MaxKDFMemory/128 {
+			return fmt.Errorf("hctr2: scrypt memory is larger than %d bytes",
+				uint64(MaxKDFMemory))
+		}
+	default:
+		return fmt.Errorf("hctr2: unknown KDF: %v", p.KDF)
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p KDFParams) MarshalText() ([]byte, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	var s string
+	switch p.KDF {
+	case Argon2id:
+		s = fmt.Sprintf("argon2id$m=%d,t=%d,p=%d,k=%d",
+			p.Memory, p.Time, p.Threads, p.KeySize)
+	case Scrypt:
+		s = fmt.Sprintf("scrypt$ln=%d,r=%d,p=%d,k=%d",
+			p.LogN, p.R, p.P, p.KeySize)
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *KDFParams) UnmarshalText(text []byte) error {
+	name, args, ok := cut(string(text), "$")
+	if !ok {
+		return fmt.Errorf("hctr2: invalid KDF parameters: %q", text)
+	}
+	var q KDFParams
+	fields := make(map[string]*uint64)
+	var m, t, threads, ln, r, par, k uint64
+	switch name {
+	case "argon2id":
+		q.KDF = Argon2id
+		fields["m"] = &m
+		fields["t"] = &t
+		fields["p"] = &threads
+	case "scrypt":
+		q.KDF = Scrypt
+		fields["ln"] = &ln
+		fields["r"] = &r
+		fields["p"] = &par
+	default:
+		return fmt.Errorf("hctr2: unknown KDF: %q", name)
+	}
+	fields["k"] = &k
+	for _, kv := range strings.Split(args, ",") {
+		key, val, ok := cut(kv, "=")
+		dst, known := fields[key]
+		if !ok || !known {
+			return fmt.Errorf("hctr2: invalid KDF parameter: %q", kv)
+		}
+		delete(fields, key)
+		x, err := strconv.ParseUint(val, 10, 32)
+		if err != nil {
+			return fmt.Errorf("hctr2: invalid KDF parameter: %q", kv)
+		}
+		*dst = x
+	}
+	if len(fields) != 0 {
+		return fmt.Errorf("hctr2: missing KDF parameters: %q", text)
+	}
+	if threads > 255 || ln > 255 {
+		return fmt.Errorf("hctr2: invalid KDF parameters: %q", text)
+	}
+	q.Memory = uint32(m)
+	q.Time = uint32(t)
+	q.Threads = uint8(threads)
+	q.LogN = uint8(ln)
+	q.R = int(r)
+	q.P = int(par)
+	q.KeySize = int(k)
+	if err := q.validate(); err != nil {
+		return err
+	}
+	*p = q
+	return nil
+}
+
+// cut is strings.Cut, which requires Go 1.18.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// DeriveKeyFromPassphrase derives an AES key for NewAES from
+// a passphrase.
+//
+// The salt must be at least MinSaltSize bytes and should be
+// unique for each key.
+func DeriveKeyFromPassphrase(pass, salt []byte, params KDFParams) ([]byte, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if len(salt) < MinSaltSize {
+		return nil, fmt.Errorf("hctr2: salt must be at least %d bytes", MinSaltSize)
+	}
+	return deriveKey(pass, salt, params)
+}
+
+// deriveKey is DeriveKeyFromPassphrase without the salt size
+// check.
+func deriveKey(pass, salt []byte, params KDFParams) ([]byte, error) {
+	switch params.KDF {
+	case Argon2id:
+		return argon2.IDKey(pass, salt, params.Time, params.Memory,
+			params.Threads, uint32(params.KeySize)), nil
+	default:
+		return scrypt.Key(pass, salt, 1<<params.LogN,
+			params.R, params.P, params.KeySize)
+	}
+}
+
+// NewAESFromPassphrase creates a HCTR2 cipher using AES with
+// a key derived from a passphrase.
+//
+// It is equivalent to calling NewAES with the result of
+// DeriveKeyFromPassphrase.
+func NewAESFromPassphrase(pass, salt []byte, params KDFParams, opts ...Option) (*Cipher, error) {
+	key, err := DeriveKeyFromPassphrase(pass, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	defer subtle.Wipe(key)
+	return NewAES(key, opts...)
+}
+
+// CalibrateKDF returns a copy of params with the cost increased
+// until deriving a key takes at least target on this machine.
+//
+// For Argon2id, only Time is changed. For scrypt, only LogN is
+// changed. The memory and parallelism parameters are left as
+// is, since they depend on the machines that must unlock the
+// key rather than on the desired latency.
+//
+// The cost is never lowered below the cost in params.
+func CalibrateKDF(params KDFParams, target time.Duration) (KDFParams, error) {
+	if err := params.validate(); err != nil {
+		return KDFParams{}, err
+	}
+	pass := []byte("hctr2 kdf calibration")
+	salt := make([]byte, MinSaltSize)
+	measure := func(p KDFParams) (time.Duration, error) {
+		start := time.Now()
+		key, err := DeriveKeyFromPassphrase(pass, salt, p)
+		if err != nil {
+			return 0, err
+		}
+		subtle.Wipe(key)
+		return time.Since(start), nil
+	}
+
+	for {
+		d, err := measure(params)
+		if err != nil {
+			return KDFParams{}, err
+		}
+		if d >= target {
+			return params, nil
+		}
+		switch params.KDF {
+		case Argon2id:
+			// The cost of Argon2id is linear in Time, so
+			// estimate the final value directly.
+			t := uint64(params.Time) * uint64(target) / uint64(d+1)
+			if t <= uint64(params.Time) {
+				t = uint64(params.Time) + 1
+			}
+			if t > 1<<16 {
+				return KDFParams{}, errors.New("hctr2: unable to reach target duration")
+			}
+			params.Time = uint32(t)
+		case Scrypt:
+			if params.LogN >= 30 {
+				return KDFParams{}, errors.New("hctr2: unable to reach target duration")
+			}
+			params.LogN++
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package hctr2
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestDeriveKeyFromPassphrase tests DeriveKeyFromPassphrase
+// against known answers.
+func TestDeriveKeyFromPassphrase(t *testing.T) {
+	pass := []byte("correct horse battery staple")
+	salt := make([]byte, 16)
+	for i := range salt {
+		salt[i] = byte(i)
+	}
+	for _, tc := range []struct {
+		params KDFParams
+		want   []byte
+	}{
+		{
+			// Computed with Python's hashlib.scrypt.
+			params: KDFParams{KDF: Scrypt, KeySize: 32, LogN: 10, R: 8, P: 1},
+			want:   unhex("9a9f74cc441de571a18c4bf8580ad51f86745d14b39065ad24ad92fc05c99515"),
+		},
+	} {
+		got, err := DeriveKeyFromPassphrase(pass, salt, tc.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Fatalf("%v: expected %x, got %x", tc.params.KDF, tc.want, got)
+		}
+	}
+}
+
+// TestArgon2id tests Argon2id against known answers from the
+// test suite of the reference implementation cited by RFC 9106,
+// https://github.com/P-H-C/phc-winner-argon2.
+//
+// The vectors in RFC 9106 itself use a secret and associated
+// data, which golang.org/x/crypto/argon2 does not support. The
+// reference vectors use an 8-byte salt, so they bypass the
+// MinSaltSize check.
+func TestArgon2id(t *testing.T) {
+	pass := []byte("password")
+	salt := []byte("somesalt")
+	for _, tc := range []struct {
+		params KDFParams
+		want   []byte
+	}{
+		{
+			params: KDFParams{KDF: Argon2id, KeySize: 32, Time: 2, Memory: 256, Threads: 1},
+			want:   unhex("9dfeb910e80bad0311fee20f9c0e2b12c17987b4cac90c2ef54d5b3021c68bfe"),
+		},
+		{
+			params: KDFParams{KDF: Argon2id, KeySize: 32, Time: 2, Memory: 256, Threads: 2},
+			want:   unhex("6d093c501fd5999645e0ea3bf620d7b8be7fd2db59c20d9fff9539da2bf57037"),
+		},
+	} {
+		if err := tc.params.validate(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := deriveKey(pass, salt, tc.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Fatalf("%+v: expected %x, got %x", tc.params, tc.want, got)
+		}
+	}
+}
+
+func TestKDFParamsText(t *testing.T) {
+	for _, p := range []KDFParams{
+		DefaultKDFParams(),
+		DefaultScryptParams(),
+		{KDF: Argon2id, KeySize: 16, Time: 1, Memory: 8, Threads: 1},
+		// Exactly MaxKDFMemory.
+		{KDF: Argon2id, KeySize: 32, Time: 1, Memory: MaxKDFMemory / 1024, Threads: 4},
+		{KDF: Scrypt, KeySize: 32, LogN: 24, R: 1, P: 1},
+	} {
+		text, err := p.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got KDFParams
+		if err := got.UnmarshalText(text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		if got != p {
+			t.Fatalf("%s: expected %+v, got %+v", text, p, got)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"argon2id",
+		"argon2id$m=65536,t=3,p=4",
+		"argon2id$m=65536,t=3,p=4,k=32,k=32",
+		"argon2id$m=65536,t=0,p=4,k=32",
+		"argon2id$m=65536,t=3,p=4,k=20",
+		"argon2id$ln=15,r=8,p=1,k=32",
+		"scrypt$ln=15,r=8,p=1,k=32,x=1",
+		"scrypt$ln=99,r=8,p=1,k=32",
+		// More than MaxKDFMemory.
+		"argon2id$m=4294967295,t=3,p=4,k=32",
+		"argon2id$m=2097153,t=3,p=4,k=32",
+		"scrypt$ln=25,r=8,p=1,k=32",
+		"scrypt$ln=1,r=16777217,p=1,k=32",
+		"pbkdf2$i=100000,k=32",
+	} {
+		var p KDFParams
+		if err := p.UnmarshalText([]byte(s)); err == nil {
+			t.Fatalf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestNewAESFromPassphrase(t *testing.T) {
+	params := KDFParams{KDF: Argon2id, KeySize: 24, Time: 1, Memory: 64, Threads: 1}
+	pass := []byte("hunter2")
+	salt := randbuf(MinSaltSize)
+
+	key, err := DeriveKeyFromPassphrase(pass, salt, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAES(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewAESFromPassphrase(pass, salt, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := randbuf(64)
+	want := make([]byte, len(plaintext))
+	a.Encrypt(want, plaintext, nil)
+	got := make([]byte, len(plaintext))
+	b.Encrypt(got, plaintext, nil)
+	if !bytes.Equal(want, got) {
+		t.Fatalf("expected %x, got %x", want, got)
+	}
+
+	if _, err := NewAESFromPassphrase(pass, salt[:MinSaltSize-1], params); err == nil {
+		t.Fatal("expected an error for a short salt")
+	}
+}
+
+func TestCalibrateKDF(t *testing.T) {
+	target := 20 * time.Millisecond
+	for _, base := range []KDFParams{
+		{KDF: Argon2id, KeySize: 32, Time: 1, Memory: 1024, Threads: 1},
+		{KDF: Scrypt, KeySize: 32, LogN: 8, R: 8, P: 1},
+	} {
+		p, err := CalibrateKDF(base, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Time < base.Time || p.LogN < base.LogN {
+			t.Fatalf("%v: cost was lowered: %+v", base.KDF, p)
+		}
+		if p.Memory != base.Memory || p.Threads != base.Threads ||
+			p.R != base.R || p.P != base.P {
+			t.Fatalf("%v: unexpected change: %+v", base.KDF, p)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build gc && !purego
+
+package hctr2
+
+import "golang.org/x/sys/cpu"
+
+// polyvalImpl reports the POLYVAL implementation selected by
+// github.com/ericlagergren/polyval.
+func polyvalImpl() string {
+	if cpu.X86.HasPCLMULQDQ {
+		return "assembly"
+	}
+	return "generic"
+}
@@ -0,0 +1,18 @@
+//go:build gc && !purego
+
+package hctr2
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/cpu"
+)
+
+// polyvalImpl reports the POLYVAL implementation selected by
+// github.com/ericlagergren/polyval.
+func polyvalImpl() string {
+	if runtime.GOOS == "darwin" || cpu.ARM64.HasPMULL {
+		return "assembly"
+	}
+	return "generic"
+}
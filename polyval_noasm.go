@@ -0,0 +1,9 @@
+//go:build !(amd64 || arm64) || !gc || purego
+
+package hctr2
+
+// polyvalImpl reports the POLYVAL implementation selected by
+// github.com/ericlagergren/polyval.
+func polyvalImpl() string {
+	return "generic"
+}
@@ -0,0 +1,36 @@
+//go:build go1.18
+
+package hctr2
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ericlagergren/hctr2/internal/ref"
+)
+
+// FuzzHCTR2 tests Cipher against the reference implementation.
+func FuzzHCTR2(f *testing.F) {
+	f.Add([]byte{0}, []byte{}, make([]byte, BlockSize))
+	f.Add([]byte{1}, []byte("tweak"), make([]byte, BlockSize+1))
+	f.Add([]byte{2}, make([]byte, 33), make([]byte, 4*BlockSize))
+
+	f.Fuzz(func(t *testing.T, seed, tweak, plaintext []byte) {
+		key, ok := fuzzKey(seed)
+		if !ok || len(plaintext) < BlockSize {
+			return
+		}
+		want := ref.Encrypt(key, tweak, plaintext)
+		for _, c := range fuzzCiphers(t, key) {
+			got := make([]byte, len(plaintext))
+			c.Encrypt(got, plaintext, tweak)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: expected %x, got %x", c.Implementation(), want, got)
+			}
+			c.Decrypt(got, got, tweak)
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("%s: expected %x, got %x", c.Implementation(), plaintext, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package rekey
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+var errNoJournal = errors.New("rekey: journal does not exist")
+
+// journalMagic identifies a journal file.
+const journalMagic = "HCTR2RKJ"
+
+const journalVersion = 1
+
+// journalHeaderSize is the size of the fixed-length part of
+// a journal:
+//
+//	magic      [8]byte
+//	version    uint32
+//	sectorSize uint32
+//	sectors    uint64
+//	next       uint64
+//	dataLen    uint64
+//
+// It is followed by dataLen bytes of data and the SHA-256 hash
+// of everything before it.
+const journalHeaderSize = 8 + 4 + 4 + 8 + 8 + 8
+
+// journal records the progress of Rekey.
+type journal struct {
+	sectorSize uint32
+	sectors    uint64
+	// next is the first sector that has not been re-encrypted.
+	next uint64
+	// data is the original ciphertext of the batch starting at
+	// next, or nil if no batch is being written.
+	data []byte
+}
+
+// offset returns the byte offset of the next sector.
+func (j *journal) offset(sectorSize int) int64 {
+	return int64(j.next) * int64(sectorSize)
+}
+
+func (j *journal) marshal() []byte {
+	buf := make([]byte, journalHeaderSize, journalHeaderSize+len(j.data)+sha256.Size)
+	copy(buf, journalMagic)
+	binary.LittleEndian.PutUint32(buf[8:], journalVersion)
+	binary.LittleEndian.PutUint32(buf[12:], j.sectorSize)
+	binary.LittleEndian.PutUint64(buf[16:], j.sectors)
+	binary.LittleEndian.PutUint64(buf[24:], j.next)
+	binary.LittleEndian.PutUint64(buf[32:], uint64(len(j.data)))
+	buf = append(buf, j.data...)
+	sum := sha256.Sum256(buf)
+	return append(buf, sum[:]...)
+}
+
+func (j *journal) unmarshal(buf []byte) error {
+	if len(buf) < journalHeaderSize+sha256.Size {
+		return errors.New("rekey: journal is too short")
+	}
+	body := buf[:len(buf)-sha256.Size]
+	sum := sha256.Sum256(body)
+	if !bytes.Equal(sum[:], buf[len(body):]) {
+		return errors.New("rekey: journal checksum mismatch")
+	}
+	if string(body[:8]) != journalMagic {
+		return errors.New("rekey: not a journal file")
+	}
+	if v := binary.LittleEndian.Uint32(body[8:]); v != journalVersion {
+		return fmt.Errorf("rekey: unknown journal version: %d", v)
+	}
+	j.sectorSize = binary.LittleEndian.Uint32(body[12:])
+	j.sectors = binary.LittleEndian.Uint64(body[16:])
+	j.next = binary.LittleEndian.Uint64(body[24:])
+	n := binary.LittleEndian.Uint64(body[32:])
+	if n != uint64(len(body)-journalHeaderSize) {
+		return errors.New("rekey: invalid journal data length")
+	}
+	if j.sectorSize == 0 || n%uint64(j.sectorSize) != 0 || j.next > j.sectors {
+		return errors.New("rekey: invalid journal")
+	}
+	if n > 0 {
+		j.data = body[journalHeaderSize:]
+	}
+	return nil
+}
+
+// readJournal reads the journal at path.
+//
+// It returns errNoJournal if the journal does not exist.
+func readJournal(path string) (*journal, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errNoJournal
+		}
+		return nil, err
+	}
+	var j journal
+	if err := j.unmarshal(buf); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// write atomically replaces the journal at path.
+func (j *journal) write(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(j.marshal()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes a rename in dir durable.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// Windows does not support syncing directories.
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
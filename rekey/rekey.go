@@ -0,0 +1,272 @@
+// Package rekey re-encrypts HCTR2-encrypted sector stores in
+// place.
+//
+// Rekey decrypts each sector with the old Cipher and encrypts it
+// with the new Cipher. Progress is recorded in a small journal
+// file so that an interrupted run can be resumed, even if it was
+// interrupted by a crash.
+//
+// Before overwriting a batch of sectors, Rekey copies the
+// original ciphertext into the journal. If the run is
+// interrupted while the batch is being written, the next run
+// restores the original ciphertext and then re-encrypts the
+// batch. A sector is therefore never left half encrypted under
+// each key.
+package rekey
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+// Device is a sector store, like an *os.File.
+//
+// If the Device also implements Syncer, Rekey calls Sync after
+// writing each batch. Otherwise, Rekey cannot guarantee that
+// a crash will not leave the Device in an unrecoverable state.
+type Device interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// Syncer flushes writes to stable storage.
+type Syncer interface {
+	Sync() error
+}
+
+// TweakFunc appends the tweak for a sector to dst and returns
+// the resulting slice.
+type TweakFunc func(dst []byte, sector uint64) []byte
+
+// SectorLE64 is a TweakFunc that encodes the sector number as
+// a 64-bit little-endian integer.
+func SectorLE64(dst []byte, sector uint64) []byte {
+	return append(dst,
+		byte(sector),
+		byte(sector>>8),
+		byte(sector>>16),
+		byte(sector>>24),
+		byte(sector>>32),
+		byte(sector>>40),
+		byte(sector>>48),
+		byte(sector>>56),
+	)
+}
+
+// Config configures Rekey.
+type Config struct {
+	// SectorSize is the size in bytes of each sector.
+	//
+	// It must be at least hctr2.BlockSize.
+	SectorSize int
+	// Sectors is the number of sectors on the device.
+	Sectors uint64
+	// Tweak computes the tweak for each sector.
+	//
+	// The same tweak is used for both the old and new keys.
+	//
+	// If nil, SectorLE64 is used.
+	Tweak TweakFunc
+	// Journal is the path to the journal file.
+	//
+	// It must be on a different device than the one being
+	// re-encrypted.
+	Journal string
+	// Workers is the number of goroutines used to encrypt
+	// each batch.
+	//
+	// If zero, runtime.GOMAXPROCS(0) is used.
+	Workers int
+	// BatchSize is the number of sectors written between each
+	// journal update.
+	//
+	// If zero, 256 is used.
+	BatchSize int
+}
+
+func (cfg *Config) validate(from, to *hctr2.Cipher) error {
+	if cfg.SectorSize < hctr2.BlockSize {
+		return fmt.Errorf("rekey: invalid sector size: %d", cfg.SectorSize)
+	}
+	if cfg.Journal == "" {
+		return errors.New("rekey: missing journal path")
+	}
+	if cfg.Workers < 0 {
+		return fmt.Errorf("rekey: invalid number of workers: %d", cfg.Workers)
+	}
+	if cfg.BatchSize < 0 {
+		return fmt.Errorf("rekey: invalid batch size: %d", cfg.BatchSize)
+	}
+	if cfg.Tweak == nil {
+		cfg.Tweak = SectorLE64
+	}
+	if cfg.Workers == 0 {
+		cfg.Workers = runtime.GOMAXPROCS(0)
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 256
+	}
+	// Check the sector geometry now instead of letting Encrypt or
+	// Decrypt panic partway through the run. Tweaks usually have
+	// the same length, so checking the first and last sectors is
+	// enough.
+	if cfg.Sectors > 0 {
+		for _, sector := range []uint64{0, cfg.Sectors - 1} {
+			tweakLen := len(cfg.Tweak(nil, sector))
+			if err := from.Check(cfg.SectorSize, tweakLen); err != nil {
+				return fmt.Errorf("rekey: old cipher: %w", err)
+			}
+			if err := to.Check(cfg.SectorSize, tweakLen); err != nil {
+				return fmt.Errorf("rekey: new cipher: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Rekey re-encrypts each sector on dev from one key to another.
+//
+// Each sector is decrypted with from and then encrypted with to.
+//
+// If the journal file exists, Rekey resumes the run that it
+// describes. Otherwise, Rekey starts from the first sector.
+//
+// After Rekey returns nil, the journal records that the run is
+// complete and calling Rekey again with the same journal is
+// a no-op. The journal should only be deleted after the new key
+// has replaced the old one: calling Rekey without the journal
+// on a device that has already been re-encrypted corrupts it.
+//
+// from and to are not used concurrently; Rekey clones them for
+// each worker.
+func Rekey(dev Device, from, to *hctr2.Cipher, cfg Config) error {
+	if err := cfg.validate(from, to); err != nil {
+		return err
+	}
+
+	j, err := readJournal(cfg.Journal)
+	if err != nil {
+		if !errors.Is(err, errNoJournal) {
+			return err
+		}
+		j = &journal{
+			sectorSize: uint32(cfg.SectorSize),
+			sectors:    cfg.Sectors,
+		}
+	}
+	if j.sectorSize != uint32(cfg.SectorSize) || j.sectors != cfg.Sectors {
+		return fmt.Errorf("rekey: journal is for %d sectors of %d bytes, not %d sectors of %d bytes",
+			j.sectors, j.sectorSize, cfg.Sectors, cfg.SectorSize)
+	}
+
+	if len(j.data) > 0 {
+		// The previous run was interrupted while writing this
+		// batch, so some of its sectors might be encrypted with
+		// the new key. Restore the original ciphertext before
+		// trying again.
+		if _, err := dev.WriteAt(j.data, j.offset(cfg.SectorSize)); err != nil {
+			return err
+		}
+		if err := syncDevice(dev); err != nil {
+			return err
+		}
+		j.data = nil
+		if err := j.write(cfg.Journal); err != nil {
+			return err
+		}
+	}
+
+	w := newWorkers(from, to, cfg)
+	buf := make([]byte, cfg.BatchSize*cfg.SectorSize)
+	for j.next < j.sectors {
+		n := uint64(cfg.BatchSize)
+		if r := j.sectors - j.next; r < n {
+			n = r
+		}
+		batch := buf[:int(n)*cfg.SectorSize]
+		off := j.offset(cfg.SectorSize)
+		// ReadAt may return io.EOF along with a full batch at the
+		// end of the device.
+		if n, err := dev.ReadAt(batch, off); err != nil &&
+			!(err == io.EOF && n == len(batch)) {
+			return err
+		}
+
+		j.data = batch
+		if err := j.write(cfg.Journal); err != nil {
+			return err
+		}
+
+		w.run(batch, j.next)
+
+		if _, err := dev.WriteAt(batch, off); err != nil {
+			return err
+		}
+		if err := syncDevice(dev); err != nil {
+			return err
+		}
+
+		j.next += n
+		j.data = nil
+		if err := j.write(cfg.Journal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncDevice(dev Device) error {
+	if s, ok := dev.(Syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// workers re-encrypts batches of sectors in parallel.
+type workers struct {
+	cfg      Config
+	from, to []*hctr2.Cipher
+}
+
+func newWorkers(from, to *hctr2.Cipher, cfg Config) *workers {
+	w := &workers{
+		cfg:  cfg,
+		from: make([]*hctr2.Cipher, cfg.Workers),
+		to:   make([]*hctr2.Cipher, cfg.Workers),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		w.from[i] = from.Clone()
+		w.to[i] = to.Clone()
+	}
+	return w
+}
+
+// run re-encrypts batch in place. The first sector in batch is
+// sector number first.
+func (w *workers) run(batch []byte, first uint64) {
+	ss := w.cfg.SectorSize
+	n := len(batch) / ss
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Workers && i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			from, to := w.from[i], w.to[i]
+			var tweak []byte
+			for k := i; k < n; k += w.cfg.Workers {
+				sector := batch[k*ss : (k+1)*ss]
+				tweak = w.cfg.Tweak(tweak[:0], first+uint64(k))
+				from.Decrypt(sector, sector, tweak)
+				to.Encrypt(sector, sector, tweak)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
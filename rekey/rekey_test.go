@@ -0,0 +1,220 @@
+package rekey
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ericlagergren/hctr2"
+)
+
+// memDevice is an in-memory Device.
+type memDevice struct {
+	buf []byte
+}
+
+func (d *memDevice) ReadAt(p []byte, off int64) (int, error) {
+	return copy(p, d.buf[off:]), nil
+}
+
+func (d *memDevice) WriteAt(p []byte, off int64) (int, error) {
+	return copy(d.buf[off:], p), nil
+}
+
+// eofDevice returns io.EOF from ReadAt when a read reaches the
+// end of the device, like *os.File.
+type eofDevice struct {
+	*memDevice
+}
+
+func (d *eofDevice) ReadAt(p []byte, off int64) (int, error) {
+	n, _ := d.memDevice.ReadAt(p, off)
+	if off+int64(n) >= int64(len(d.buf)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+var errCrash = errors.New("crash")
+
+// crashDevice simulates a crash by writing only part of the
+// n-th write.
+type crashDevice struct {
+	*memDevice
+	n int
+}
+
+func (d *crashDevice) WriteAt(p []byte, off int64) (int, error) {
+	d.n--
+	if d.n == 0 {
+		p = p[:len(p)/2+1]
+		d.memDevice.WriteAt(p, off)
+		return len(p), errCrash
+	}
+	return d.memDevice.WriteAt(p, off)
+}
+
+func randbuf(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+func newCipher(t *testing.T) *hctr2.Cipher {
+	c, err := hctr2.NewAES(randbuf(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// setup returns a device encrypted with from and its plaintext.
+func setup(t *testing.T, from *hctr2.Cipher, cfg Config) (*memDevice, []byte) {
+	plaintext := randbuf(int(cfg.Sectors) * cfg.SectorSize)
+	dev := &memDevice{buf: make([]byte, len(plaintext))}
+	var tweak []byte
+	for i := uint64(0); i < cfg.Sectors; i++ {
+		off := int(i) * cfg.SectorSize
+		tweak = SectorLE64(tweak[:0], i)
+		from.Encrypt(dev.buf[off:], plaintext[off:off+cfg.SectorSize], tweak)
+	}
+	return dev, plaintext
+}
+
+// check checks that dev is plaintext encrypted with to.
+func check(t *testing.T, dev *memDevice, to *hctr2.Cipher, plaintext []byte, cfg Config) {
+	t.Helper()
+
+	got := make([]byte, len(dev.buf))
+	var tweak []byte
+	for i := uint64(0); i < cfg.Sectors; i++ {
+		off := int(i) * cfg.SectorSize
+		tweak = SectorLE64(tweak[:0], i)
+		to.Decrypt(got[off:], dev.buf[off:off+cfg.SectorSize], tweak)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatal("device was not re-encrypted correctly")
+	}
+}
+
+func TestRekey(t *testing.T) {
+	from, to := newCipher(t), newCipher(t)
+	cfg := Config{
+		SectorSize: 512,
+		Sectors:    100,
+		Journal:    filepath.Join(t.TempDir(), "journal"),
+		Workers:    3,
+		BatchSize:  7,
+	}
+	dev, plaintext := setup(t, from, cfg)
+	if err := Rekey(dev, from, to, cfg); err != nil {
+		t.Fatal(err)
+	}
+	check(t, dev, to, plaintext, cfg)
+
+	// The journal records that the run is complete, so running
+	// it again must not touch the device.
+	if err := Rekey(dev, from, to, cfg); err != nil {
+		t.Fatal(err)
+	}
+	check(t, dev, to, plaintext, cfg)
+}
+
+// TestRekeyResume tests resuming after crashing in the middle of
+// writing a batch.
+func TestRekeyResume(t *testing.T) {
+	for n := 1; n <= 5; n++ {
+		from, to := newCipher(t), newCipher(t)
+		cfg := Config{
+			SectorSize: 64,
+			Sectors:    33,
+			Journal:    filepath.Join(t.TempDir(), "journal"),
+			Workers:    2,
+			BatchSize:  8,
+		}
+		dev, plaintext := setup(t, from, cfg)
+		err := Rekey(&crashDevice{memDevice: dev, n: n}, from, to, cfg)
+		if !errors.Is(err, errCrash) {
+			t.Fatalf("#%d: expected %v, got %v", n, errCrash, err)
+		}
+		if err := Rekey(dev, from, to, cfg); err != nil {
+			t.Fatalf("#%d: %v", n, err)
+		}
+		check(t, dev, to, plaintext, cfg)
+	}
+}
+
+func TestRekeyJournalMismatch(t *testing.T) {
+	from, to := newCipher(t), newCipher(t)
+	cfg := Config{
+		SectorSize: 64,
+		Sectors:    4,
+		Journal:    filepath.Join(t.TempDir(), "journal"),
+	}
+	dev, _ := setup(t, from, cfg)
+	if err := Rekey(dev, from, to, cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Sectors++
+	if err := Rekey(dev, from, to, cfg); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// TestRekeyCheck tests that Rekey rejects ciphers that cannot
+// encrypt the sectors before touching the device or journal.
+func TestRekeyCheck(t *testing.T) {
+	for i, opt := range []hctr2.Option{
+		hctr2.MaxSize(32),
+		hctr2.MinSize(128),
+		hctr2.TweakSize(16),
+	} {
+		from := newCipher(t)
+		to, err := hctr2.NewAES(randbuf(32), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := Config{
+			SectorSize: 64,
+			Sectors:    4,
+			Journal:    filepath.Join(t.TempDir(), "journal"),
+		}
+		dev, _ := setup(t, from, cfg)
+		want := append([]byte(nil), dev.buf...)
+		if err := Rekey(dev, from, to, cfg); err == nil {
+			t.Fatalf("#%d: expected an error", i)
+		}
+		if err := Rekey(dev, to, from, cfg); err == nil {
+			t.Fatalf("#%d: expected an error", i)
+		}
+		if !bytes.Equal(dev.buf, want) {
+			t.Fatalf("#%d: device was modified", i)
+		}
+		if _, err := os.Stat(cfg.Journal); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("#%d: journal was written: %v", i, err)
+		}
+	}
+}
+
+// TestRekeyEOF tests a device that returns io.EOF with the last
+// batch.
+func TestRekeyEOF(t *testing.T) {
+	from, to := newCipher(t), newCipher(t)
+	cfg := Config{
+		SectorSize: 64,
+		Sectors:    10,
+		Journal:    filepath.Join(t.TempDir(), "journal"),
+		BatchSize:  4,
+	}
+	dev, plaintext := setup(t, from, cfg)
+	if err := Rekey(&eofDevice{dev}, from, to, cfg); err != nil {
+		t.Fatal(err)
+	}
+	check(t, dev, to, plaintext, cfg)
+}
@@ -0,0 +1,221 @@
+package hctr2
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	aesasm "github.com/ericlagergren/hctr2/internal/aes"
+)
+
+// ErrSelfTest is returned by SelfTest when a known-answer test
+// fails.
+var ErrSelfTest = errors.New("hctr2: self-test failed")
+
+// SelfTest runs known-answer tests for AES, XCTR, and HCTR2
+// using each backend supported by the CPU.
+//
+// It returns an error wrapping ErrSelfTest if any test fails.
+func SelfTest() error {
+	backends := []bool{false}
+	if aesasm.Supported {
+		backends = append(backends, true)
+	}
+	for _, asm := range backends {
+		impl := backend(asm)
+		for _, fn := range []func(bool) error{
+			selfTestAES,
+			selfTestXCTR,
+			selfTestHCTR2,
+		} {
+			if err := fn(asm); err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrSelfTest, impl, err)
+			}
+		}
+	}
+	return nil
+}
+
+var selfTest struct {
+	once sync.Once
+	err  error
+}
+
+// selfTestOnce runs SelfTest once and returns its result.
+//
+// A failure is permanent: every later call returns the same
+// error.
+func selfTestOnce() error {
+	selfTest.once.Do(func() {
+		selfTest.err = SelfTest()
+	})
+	return selfTest.err
+}
+
+// selfTestBlock creates the block cipher used by the
+// known-answer tests.
+//
+// Tests replace it to check that a faulty block cipher fails
+// the self-test.
+var selfTestBlock = newCipher
+
+// selfTestAES tests AES-128, AES-192, and AES-256 with the
+// examples from FIPS 197, appendix C.
+func selfTestAES(asm bool) error {
+	for _, v := range []struct {
+		key, plaintext, ciphertext string
+	}{
+		{
+			key:        "000102030405060708090a0b0c0d0e0f",
+			plaintext:  "00112233445566778899aabbccddeeff",
+			ciphertext: "69c4e0d86a7b0430d8cdb78070b4c55a",
+		},
+		{
+			key:        "000102030405060708090a0b0c0d0e0f1011121314151617",
+			plaintext:  "00112233445566778899aabbccddeeff",
+			ciphertext: "dda97ca4864cdfe06eaf70a0ec0d7191",
+		},
+		{
+			key:        "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+			plaintext:  "00112233445566778899aabbccddeeff",
+			ciphertext: "8ea2b7ca516745bfeafc49904b496089",
+		},
+	} {
+		block := selfTestBlock(mustUnhex(v.key), asm)
+		plaintext := mustUnhex(v.plaintext)
+		want := mustUnhex(v.ciphertext)
+		got := make([]byte, BlockSize)
+		block.Encrypt(got, plaintext)
+		if !bytes.Equal(got, want) {
+			return errors.New("AES encryption")
+		}
+		block.Decrypt(got, got)
+		if !bytes.Equal(got, plaintext) {
+			return errors.New("AES decryption")
+		}
+	}
+	return nil
+}
+
+// selfTestXCTR tests XCTR with test vectors from
+// github.com/google/hctr2.
+//
+// The vectors cover AES-128 and AES-192, full blocks, more than
+// eight blocks, and a partial final block.
+func selfTestXCTR(asm bool) error {
+	for _, v := range []struct {
+		key, nonce, src, want string
+	}{
+		{
+			key:   "5b1730941931a1ae248e421e82e6ecb8",
+			nonce: "d12eb9b8f849eb6806eb653334a2ebf0",
+			src: "" +
+				"1975ec59601b7a3e624687f0deab8136635311a01fce2585496b28fa1c92e518" +
+				"38140079f29eebfc36a76be1e5cf0448446dbd64b3cb78058d7f9aaf3ccf6c45" +
+				"6c7c464ca8c01ee433a57bbb26d9c0329d8ab3f33d52e6484c9b4c6ea4a3ad66" +
+				"5648d5983a93c485e989caa6c1c8e7f8c3e9efbe77e6d13aa699c82ddf400f44",
+			want: "" +
+				"c61a011a00ba04ff10d17e5dad91de8c085595aed7227740f0331b51effe3d67" +
+				"dfc49f39476793abaa3755fe41e0bacd25027c6151a1cc727a2026b90668bd19" +
+				"c52e1b754a40b2d2c4eed85ba4557d25fc014d6f0afd375d3e67c03572537be2" +
+				"d6195b926c3a8c2ae2c2a24f2af2b51565c58d97f9bf8c98e4501af276550749",
+		},
+		{
+			key:   "17a6013d5dd6ef2d698f4c545bae43f0",
+			nonce: "a91b47602682f71c80f888ddfb44d9da",
+			src: "" +
+				"f767cda604655399905ca25674d79df20b037f4ea784722bf0a5bfe69a623afe" +
+				"695c937923866485eb13b15ad54839a070fb069ad7125ab9beed2c8164f7cf80" +
+				"eee628322d374c32f41f2321e9c8c9bf54bccfb4c26539dfa5fb1411ed6238cf" +
+				"9b5811dde9bd3757754c9ed5670a48c60d054eb106d7ec2e9e59de4fab38bbe5" +
+				"87045a2c2aa28f3ce7e146a9499f24ad2db0554064d5da7e1e77b8297273c384" +
+				"cdf394905876c92c2aad56de3318b63b10e9e98df0a97f05f7b58c137e113d1e" +
+				"02bb5bea69ff85cf6a189745e396ba4d2d7a7078152ce9dc4e09925704d80ba6" +
+				"20717647769689a0d929a25a06db5639603359049589f6181d7075853ab76e",
+			want: "" +
+				"e1e73fd36ab92f6437c5a4e9ca0aa1d6ea7d39e5e6cc805474312a0433798c8e" +
+				"4d478428279b3c585458204f7001525bac9561495fefbaced77456e7bbe03cd0" +
+				"7fa92357332af6cbbe421495a8f97a7e12533ae213fe2d89ebacd7a8a5f827f3" +
+				"749a6563d1983a7e277bc020004df4e57b69a6a8065085b67fac7fda1ff53756" +
+				"9b2fd3866b70bd0e559a9d4b08b55b7bd47cb47149924a1eed6d11094772326a" +
+				"975336aff306062c69f159003695282ab6cd102184735c9686142c3d02db539a" +
+				"61deea99847a27f6f7c849734bb8ebd34133dd0968e264b85f7574979154dac2" +
+				"732c1e5a8448011a0d8b0adf072eee771d17417ac93363fa9fc374575f034c",
+		},
+		{
+			key:   "c8a02767043feda5b40c51912d277733a5fc2a9f78d81c68",
+			nonce: "83991ae284caa9168dc42d1b67c88621",
+			src:   "d62285b85d7e262ebe049d0c0391454a36",
+			want:  "0f44a96272ec12263ac68326625eb71305",
+		},
+	} {
+		c, err := selfTestCipher(mustUnhex(v.key), asm)
+		if err != nil {
+			return err
+		}
+		src := mustUnhex(v.src)
+		want := mustUnhex(v.want)
+		got := make([]byte, len(src))
+		c.xctr(got, src, (*[BlockSize]byte)(mustUnhex(v.nonce)))
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("XCTR-AES%d", len(v.key)*4)
+		}
+	}
+	return nil
+}
+
+// selfTestHCTR2 tests HCTR2 with a test vector from
+// github.com/google/hctr2.
+func selfTestHCTR2(asm bool) error {
+	key := mustUnhex("43f009643fc62d7f680c4aba6d76a588b2ce019b96931df3d5c9aeee04b94577")
+	tweak := mustUnhex("ad457f4eaeb3b1c79dc174c1b29c394e")
+	plaintext := mustUnhex("" +
+		"1f05581a582d290e564f1be04aa7bf34cb1e72b004f46415b9f9de8d01c97876" +
+		"c11c224932c6e865bd24052354df19c5e23a9a24703235be1c7f99ac11aa073f" +
+		"7188483474cad5e3624208b8842aa477d1c73f00039b85a9abb6a1cc5234eb89" +
+		"67ccab54b065d1d39e24240c7bf0e089d73bb3819f79dd998289a0d8e5d1c759" +
+		"de3eae162cd2e89333685aec450a2827d96d4bc616f2ac6ee1934b9f5ceb6ce9" +
+		"fe045032ae30cc9749f1affa1443b77783c1bf7cb2813ab0af5ab070922fa781" +
+		"77dc0cf06a4f9961bd94c9c7b5154697e6a71bd9f9658fd10adc8dded7ddcda0" +
+		"b18c5fe4e082423d111acd5a4a7e7a821c1f523dfc30db1ac482db86acf3b8")
+	want := mustUnhex("" +
+		"cd61ec8494d044d75cab0b9571f826b3d3c0d2105b938147749842c832cdfc89" +
+		"8a0f9826feb9ec90d92c1f7f3a5a5f8b569753981f7c4c81417cb5d0ed7f4663" +
+		"64bc7a2c8ddaa856da839585baf353ce79fc0851913bfaaaf0fdb6f3dbf82a5d" +
+		"5993ac589dd3409410a5f4621fff0699054bcc829a2585ae30a129b924bc32a4" +
+		"1f7c72c92fa18d0b7767acd2557eb43b0bdb6e66712c86198872ee3b945e4529" +
+		"645dee66221bf59f434f47af3732ace226ef04ca7b4f7f2b1d9ba078cf1141c6" +
+		"21dbb828222f0e8c096a0fcfc85c9c1db02f132c8d4527ecd77f5192c5673414" +
+		"8a3d91e389f9e8f4380d3e29a6c992e6ad5d94acc7bc91a9e9b3eada6f16be")
+
+	c, err := selfTestCipher(key, asm)
+	if err != nil {
+		return err
+	}
+	got := make([]byte, len(plaintext))
+	c.Encrypt(got, plaintext, tweak)
+	if !bytes.Equal(got, want) {
+		return errors.New("HCTR2 encryption")
+	}
+	c.Decrypt(got, got, tweak)
+	if !bytes.Equal(got, plaintext) {
+		return errors.New("HCTR2 decryption")
+	}
+	return nil
+}
+
+// selfTestCipher creates a Cipher for the backend selected by
+// asm without running the power-on self-test.
+func selfTestCipher(key []byte, asm bool) (*Cipher, error) {
+	return newCipherWithBlock(selfTestBlock(key, asm))
+}
+
+func mustUnhex(s string) []byte {
+	p, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
@@ -0,0 +1,6 @@
+//go:build !hctr2_selftest
+
+package hctr2
+
+// powerOnSelfTest is true if New runs SelfTest.
+const powerOnSelfTest = false
@@ -0,0 +1,40 @@
+//go:build hctr2_selftest
+
+package hctr2
+
+import (
+	"crypto/aes"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// resetSelfTest forgets the result of the power-on self-test
+// now and when the test finishes.
+func resetSelfTest(t *testing.T) {
+	reset := func() {
+		selfTest.once = sync.Once{}
+		selfTest.err = nil
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// TestPowerOnSelfTestFault tests that New and NewAES fail if the
+// power-on self-test fails.
+func TestPowerOnSelfTestFault(t *testing.T) {
+	resetSelfTest(t)
+	injectFault(t)
+
+	key := make([]byte, 16)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(block); !errors.Is(err, ErrSelfTest) {
+		t.Fatalf("New: expected %v, got %v", ErrSelfTest, err)
+	}
+	if _, err := NewAES(key); !errors.Is(err, ErrSelfTest) {
+		t.Fatalf("NewAES: expected %v, got %v", ErrSelfTest, err)
+	}
+}
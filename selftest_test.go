@@ -0,0 +1,61 @@
+package hctr2
+
+import (
+	"crypto/cipher"
+	"errors"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		t.Fatal(err)
+	}
+	if err := selfTestOnce(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// faultyBlock is a block cipher that flips the low bit of each
+// block it encrypts.
+type faultyBlock struct {
+	cipher.Block
+}
+
+func (b faultyBlock) Encrypt(dst, src []byte) {
+	b.Block.Encrypt(dst, src)
+	dst[0] ^= 1
+}
+
+// injectFault makes the known-answer tests use faultyBlock
+// until the test finishes.
+func injectFault(t *testing.T) {
+	t.Helper()
+	orig := selfTestBlock
+	selfTestBlock = func(key []byte, asm bool) cipher.Block {
+		return faultyBlock{orig(key, asm)}
+	}
+	t.Cleanup(func() {
+		selfTestBlock = orig
+	})
+}
+
+// TestSelfTestFault tests that each known-answer test detects
+// a faulty block cipher and that SelfTest reports it.
+func TestSelfTestFault(t *testing.T) {
+	injectFault(t)
+
+	for _, asm := range []bool{false, true} {
+		for name, fn := range map[string]func(bool) error{
+			"AES":   selfTestAES,
+			"XCTR":  selfTestXCTR,
+			"HCTR2": selfTestHCTR2,
+		} {
+			if err := fn(asm); err == nil {
+				t.Errorf("%s (asm=%t): expected an error", name, asm)
+			}
+		}
+	}
+	if err := SelfTest(); !errors.Is(err, ErrSelfTest) {
+		t.Fatalf("expected %v, got %v", ErrSelfTest, err)
+	}
+}
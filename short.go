@@ -0,0 +1,88 @@
+package hctr2
+
+import "encoding/binary"
+
+// feistelRounds is the number of Feistel rounds used for short
+// messages.
+const feistelRounds = 10
+
+// feistel encrypts or decrypts a message shorter than BlockSize.
+//
+// See the documentation for Encrypt.
+func (c *Cipher) feistel(dst, src, tweak []byte, seal bool) {
+	n := len(src)
+
+	// T' ← POLYVAL(h', bin(|T|) || pad(T))
+	var tt [BlockSize]byte
+	p := c.hs
+	binary.LittleEndian.PutUint64(tt[0:8], uint64(len(tweak))*8)
+	p.Update(tt[:])
+	if len(tweak) >= BlockSize {
+		m := len(tweak) &^ (BlockSize - 1)
+		p.Update(tweak[:m])
+		tweak = tweak[m:]
+	}
+	if len(tweak) > 0 {
+		var block [BlockSize]byte
+		copy(block[:], tweak)
+		p.Update(block[:])
+	}
+	p.Sum(tt[:0])
+
+	// A || B ← P, |A| = |B| = 4*|P| bits
+	//
+	// Each half is n nibbles.
+	var a, b uint64
+	for i := 0; i < 2*n; i++ {
+		x := uint64(src[i/2]>>4) & 0xf
+		if i%2 == 1 {
+			x = uint64(src[i/2]) & 0xf
+		}
+		if i < n {
+			a = a<<4 | x
+		} else {
+			b = b<<4 | x
+		}
+	}
+	mask := uint64(1)<<(4*uint(n)) - 1
+
+	var f [BlockSize]byte
+	round := func(i int, x uint64) uint64 {
+		binary.LittleEndian.PutUint64(f[0:8], x)
+		f[8] = byte(i)
+		f[9] = byte(n)
+		for j := 10; j < 15; j++ {
+			f[j] = 0
+		}
+		f[15] = 0x80
+		xorBlock(&f, &f, &tt)
+		c.block.Encrypt(f[:], f[:])
+		return binary.LittleEndian.Uint64(f[0:8]) & mask
+	}
+	if seal {
+		for i := 0; i < feistelRounds; i++ {
+			a, b = b, a^round(i, b)
+		}
+	} else {
+		for i := feistelRounds - 1; i >= 0; i-- {
+			a, b = b^round(i, a), a
+		}
+	}
+
+	// C ← A || B
+	for i := 2*n - 1; i >= 0; i-- {
+		var x byte
+		if i < n {
+			x = byte(a & 0xf)
+			a >>= 4
+		} else {
+			x = byte(b & 0xf)
+			b >>= 4
+		}
+		if i%2 == 1 {
+			dst[i/2] = dst[i/2]&0xf0 | x
+		} else {
+			dst[i/2] = dst[i/2]&0x0f | x<<4
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package hctr2
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// TestShortMessages tests encrypting then decrypting messages
+// shorter than BlockSize.
+func TestShortMessages(t *testing.T) {
+	runTests(t, testShortMessages)
+}
+
+func testShortMessages(t *testing.T) {
+	for _, keyLen := range testKeySizes {
+		t.Run(fmt.Sprintf("AES-%d", keyLen*8), func(t *testing.T) {
+			c, err := NewAES(randbuf(keyLen), ShortMessages())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for n := 1; n <= BlockSize+1; n++ {
+				for _, tweak := range [][]byte{nil, randbuf(3), randbuf(40)} {
+					plaintext := randbuf(n)
+					ciphertext := make([]byte, n)
+					c.Encrypt(ciphertext, plaintext, tweak)
+
+					got := make([]byte, n)
+					c.Decrypt(got, ciphertext, tweak)
+					if !bytes.Equal(got, plaintext) {
+						t.Fatalf("%d: expected %x, got %x", n, plaintext, got)
+					}
+
+					// In place.
+					c.Encrypt(got, got, tweak)
+					if !bytes.Equal(got, ciphertext) {
+						t.Fatalf("%d: expected %x, got %x", n, ciphertext, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestShortMessagesPermutation tests that encrypting one-byte
+// messages is a permutation that depends on the tweak.
+func TestShortMessagesPermutation(t *testing.T) {
+	c, err := NewAES(randbuf(16), ShortMessages())
+	if err != nil {
+		t.Fatal(err)
+	}
+	perm := func(tweak []byte) [256]byte {
+		var p [256]byte
+		seen := make(map[byte]bool)
+		for i := range p {
+			var b [1]byte
+			c.Encrypt(b[:], []byte{byte(i)}, tweak)
+			if seen[b[0]] {
+				t.Fatalf("%x: not a permutation", tweak)
+			}
+			seen[b[0]] = true
+			p[i] = b[0]
+		}
+		return p
+	}
+	if perm(nil) == perm([]byte{0}) {
+		t.Fatal("permutation does not depend on the tweak")
+	}
+}
+
+// TestShortMessagesVector tests short messages against vectors
+// computed with an independent implementation.
+func TestShortMessagesVector(t *testing.T) {
+	runTests(t, func(t *testing.T) {
+		key := unhex("000102030405060708090a0b0c0d0e0f")
+		c, err := NewAES(key, ShortMessages())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range []struct {
+			plaintext, tweak, ciphertext string
+		}{
+			{"00", "", "9b"},
+			{"0011223344", "0102", "1826c1d69b"},
+			{"000102030405060708090a0b0c0d0e", "000102030405060708090a0b0c0d0e0f10", "d0793d9457bc2d6ed77ea86c19f008"},
+		} {
+			plaintext := unhex(tc.plaintext)
+			got := make([]byte, len(plaintext))
+			c.Encrypt(got, plaintext, unhex(tc.tweak))
+			if want := unhex(tc.ciphertext); !bytes.Equal(got, want) {
+				t.Fatalf("%s: expected %x, got %x", tc.plaintext, want, got)
+			}
+		}
+	})
+}
+
+func TestShortMessagesDisabled(t *testing.T) {
+	c, err := NewAES(randbuf(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Check(BlockSize-1, 0); err == nil {
+		t.Fatal("expected an error")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	buf := make([]byte, BlockSize-1)
+	c.Encrypt(buf, buf, nil)
+}
@@ -0,0 +1,134 @@
+// Package xts implements AES-XTS with the same Encrypt and
+// Decrypt API as hctr2.Cipher.
+//
+// It exists to migrate data encrypted with AES-XTS to HCTR2: a
+// migration tool can decrypt each sector with a Cipher from
+// this package and encrypt it with an hctr2.Cipher. New data
+// should use HCTR2.
+//
+// XTS is specified in IEEE Std 1619-2018. This package does not
+// implement ciphertext stealing, so sectors must be a multiple
+// of the block size.
+//
+// The block cipher is the same AES implementation used by
+// hctr2.NewAES. Its only multi-block assembly computes the XCTR
+// keystream, which XTS cannot use, so XTS encrypts one block at
+// a time.
+//
+// The original HCTR mode is not implemented because there are
+// no test vectors to check it against.
+package xts
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/xts"
+
+	"github.com/ericlagergren/hctr2"
+	aesasm "github.com/ericlagergren/hctr2/internal/aes"
+)
+
+const (
+	// BlockSize is the size of an AES block.
+	BlockSize = aes.BlockSize
+	// TweakSize is the size of a tweak.
+	//
+	// The tweak is the 64-bit little-endian sector number.
+	TweakSize = 8
+	// MaxSectorSize is the largest sector that can be encrypted.
+	//
+	// IEEE Std 1619-2018 limits a data unit to 2^20 blocks.
+	MaxSectorSize = 1 << 24
+)
+
+// Cipher is an instance of AES-XTS.
+//
+// It is safe for concurrent use.
+type Cipher struct {
+	c *xts.Cipher
+}
+
+// NewAES creates an AES-XTS cipher.
+//
+// The key is the concatenation of the data key and the tweak
+// key and should be either 32, 48, or 64 bytes to choose
+// AES-128-XTS, AES-192-XTS, or AES-256-XTS, respectively.
+//
+// The block cipher uses the same AES implementation as
+// hctr2.NewAES.
+func NewAES(key []byte) (*Cipher, error) {
+	return newAES(key, func(key []byte) (cipher.Block, error) {
+		return aesasm.NewCipher(key), nil
+	})
+}
+
+// newAES creates an AES-XTS cipher using newBlock to create each
+// AES block cipher.
+func newAES(key []byte, newBlock func([]byte) (cipher.Block, error)) (*Cipher, error) {
+	switch len(key) {
+	case 32, 48, 64:
+		// OK
+	default:
+		return nil, aes.KeySizeError(len(key))
+	}
+	c, err := xts.NewCipher(newBlock, key)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{c: c}, nil
+}
+
+// Check reports whether a sector of length n can be encrypted
+// or decrypted with a tweak of length tweakLen.
+//
+// Callers that handle untrusted lengths should use Check before
+// calling Encrypt or Decrypt, both of which panic if Check would
+// return an error.
+func (c *Cipher) Check(n, tweakLen int) error {
+	if n < BlockSize || n > MaxSectorSize || n%BlockSize != 0 {
+		return fmt.Errorf("%w: %d", hctr2.ErrMessageSize, n)
+	}
+	if tweakLen != TweakSize {
+		return fmt.Errorf("%w: %d != %d", hctr2.ErrTweakSize, tweakLen, TweakSize)
+	}
+	return nil
+}
+
+// Encrypt encrypts the sector plaintext with tweak and writes
+// the result to ciphertext.
+//
+// The length of plaintext must be a non-zero multiple of
+// BlockSize no larger than MaxSectorSize, and tweak must be
+// TweakSize bytes.
+//
+// The length of ciphertext must be greater than or equal to the
+// length of plaintext.
+//
+// ciphertext and plaintext must overlap entirely or not at all.
+func (c *Cipher) Encrypt(ciphertext, plaintext, tweak []byte) {
+	if err := c.Check(len(plaintext), len(tweak)); err != nil {
+		panic(err)
+	}
+	c.c.Encrypt(ciphertext, plaintext, binary.LittleEndian.Uint64(tweak))
+}
+
+// Decrypt decrypts the sector ciphertext with tweak and writes
+// the result to plaintext.
+//
+// The length of ciphertext must be a non-zero multiple of
+// BlockSize no larger than MaxSectorSize, and tweak must be
+// TweakSize bytes.
+//
+// The length of plaintext must be greater than or equal to the
+// length of ciphertext.
+//
+// plaintext and ciphertext must overlap entirely or not at all.
+func (c *Cipher) Decrypt(plaintext, ciphertext, tweak []byte) {
+	if err := c.Check(len(ciphertext), len(tweak)); err != nil {
+		panic(err)
+	}
+	c.c.Decrypt(plaintext, ciphertext, binary.LittleEndian.Uint64(tweak))
+}
@@ -0,0 +1,189 @@
+package xts
+
+import (
+	"bytes"
+	stdaes "crypto/aes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/xts"
+
+	"github.com/ericlagergren/hctr2"
+	"github.com/ericlagergren/hctr2/internal/aes"
+)
+
+func unhex(s string) []byte {
+	p, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func randbuf(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+func sector(n uint64) []byte {
+	b := make([]byte, TweakSize)
+	binary.LittleEndian.PutUint64(b, n)
+	return b
+}
+
+type newFunc func(key []byte) (*Cipher, error)
+
+// runTests runs test with the assembly AES, if supported, and
+// crypto/aes.
+func runTests(t *testing.T, test func(t *testing.T, newCipher newFunc)) {
+	if aes.Supported {
+		t.Run("assembly", func(t *testing.T) {
+			test(t, NewAES)
+		})
+	}
+	t.Run("stdlib", func(t *testing.T) {
+		test(t, func(key []byte) (*Cipher, error) {
+			return newAES(key, stdaes.NewCipher)
+		})
+	})
+}
+
+// TestVectors tests Cipher with vectors from IEEE Std 1619-2018,
+// appendix B.
+func TestVectors(t *testing.T) {
+	test := func(t *testing.T, newCipher newFunc) {
+		for i, v := range []struct {
+			key        string
+			sector     uint64
+			plaintext  string
+			ciphertext string
+		}{
+			{
+				key:        "0000000000000000000000000000000000000000000000000000000000000000",
+				sector:     0,
+				plaintext:  "0000000000000000000000000000000000000000000000000000000000000000",
+				ciphertext: "917cf69ebd68b2ec9b9fe9a3eadda692cd43d2f59598ed858c02c2652fbf922e",
+			},
+			{
+				key:        "1111111111111111111111111111111122222222222222222222222222222222",
+				sector:     0x3333333333,
+				plaintext:  "4444444444444444444444444444444444444444444444444444444444444444",
+				ciphertext: "c454185e6a16936e39334038acef838bfb186fff7480adc4289382ecd6d394f0",
+			},
+			{
+				key:        "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f022222222222222222222222222222222",
+				sector:     0x3333333333,
+				plaintext:  "4444444444444444444444444444444444444444444444444444444444444444",
+				ciphertext: "af85336b597afc1a900b2eb21ec949d292df4c047e0b21532186a5971a227a89",
+			},
+		} {
+			c, err := newCipher(unhex(v.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext := unhex(v.plaintext)
+			want := unhex(v.ciphertext)
+			got := make([]byte, len(plaintext))
+			c.Encrypt(got, plaintext, sector(v.sector))
+			if !bytes.Equal(got, want) {
+				t.Fatalf("#%d: expected %x, got %x", i, want, got)
+			}
+			c.Decrypt(got, got, sector(v.sector))
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("#%d: expected %x, got %x", i, plaintext, got)
+			}
+		}
+	}
+	runTests(t, test)
+}
+
+// TestStdlib tests Cipher against x/crypto/xts with crypto/aes.
+func TestStdlib(t *testing.T) {
+	test := func(t *testing.T, newCipher newFunc) {
+		for _, keySize := range []int{32, 48, 64} {
+			key := randbuf(keySize)
+			c, err := newCipher(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref, err := xts.NewCipher(stdaes.NewCipher, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for n := BlockSize; n <= 4096; n *= 2 {
+				plaintext := randbuf(n)
+				sectorNum := uint64(n) * 0x0123456789
+				want := make([]byte, n)
+				ref.Encrypt(want, plaintext, sectorNum)
+				got := make([]byte, n)
+				c.Encrypt(got, plaintext, sector(sectorNum))
+				if !bytes.Equal(got, want) {
+					t.Fatalf("%d, %d: expected %x, got %x", keySize, n, want, got)
+				}
+			}
+		}
+	}
+	runTests(t, test)
+}
+
+// TestMigrate tests moving a sector from AES-XTS to HCTR2.
+func TestMigrate(t *testing.T) {
+	old, err := NewAES(randbuf(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := hctr2.NewAES(randbuf(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := randbuf(4096)
+	tweak := sector(42)
+	buf := make([]byte, len(plaintext))
+	old.Encrypt(buf, plaintext, tweak)
+
+	old.Decrypt(buf, buf, tweak)
+	c.Encrypt(buf, buf, tweak)
+
+	c.Decrypt(buf, buf, tweak)
+	if !bytes.Equal(buf, plaintext) {
+		t.Fatal("migrated sector does not match")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c, err := NewAES(randbuf(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []struct {
+		n, tweakLen int
+		err         error
+	}{
+		{BlockSize, TweakSize, nil},
+		{MaxSectorSize, TweakSize, nil},
+		{0, TweakSize, hctr2.ErrMessageSize},
+		{BlockSize + 1, TweakSize, hctr2.ErrMessageSize},
+		{MaxSectorSize + BlockSize, TweakSize, hctr2.ErrMessageSize},
+		{BlockSize, 0, hctr2.ErrTweakSize},
+		{BlockSize, 16, hctr2.ErrTweakSize},
+	} {
+		err := c.Check(v.n, v.tweakLen)
+		if !errors.Is(err, v.err) || (err == nil) != (v.err == nil) {
+			t.Fatalf("(%d, %d): expected %v, got %v", v.n, v.tweakLen, v.err, err)
+		}
+	}
+}
+
+func TestKeySize(t *testing.T) {
+	for _, n := range []int{0, 16, 24, 33, 128} {
+		if _, err := NewAES(make([]byte, n)); err == nil {
+			t.Fatalf("%d: expected an error", n)
+		}
+	}
+}